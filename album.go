@@ -0,0 +1,214 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Matcher"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// albumFile lists albums that are downloaded as a whole, one "<artist> - <album>" per
+// line. A playlist track of such an album fetches the complete folder its file is in.
+const albumFile = "albums"
+
+// albumFoldersFile remembers the folder queued for every album, so the other playlist
+// tracks of the album are taken from it instead of being searched for.
+const albumFoldersFile = "album-folders.json"
+
+// AlbumFolder is a remote folder queued for an album. Files are named with their
+// directory, as transfers are.
+type AlbumFolder struct {
+	Username  string            `json:"username"`
+	Directory string            `json:"directory"`
+	Files     []ApiClients.File `json:"files"`
+}
+
+var albumMutex sync.Mutex
+
+// albumKey is how an album is written in the album file, lowercased.
+func albumKey(track ApiClients.Track) string {
+	if track.Album == "" || len(track.Artists) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(track.Artists[0] + " - " + track.Album)
+}
+
+func readAlbumList() []string {
+	contents, err := os.ReadFile(albumFile)
+	if err != nil {
+		return nil
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		if strings.TrimSpace(line) != "" {
+			entries = append(entries, strings.TrimSpace(line))
+		}
+	}
+
+	return entries
+}
+
+// wantsAlbum reports whether the album of the track is flagged for full download.
+func wantsAlbum(track ApiClients.Track) bool {
+	key := albumKey(track)
+	if key == "" {
+		return false
+	}
+	for _, entry := range readAlbumList() {
+		if strings.ToLower(entry) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// preferAlbumFolder moves candidates from folders named after the album of the track
+// ahead, keeping the order within both groups.
+func preferAlbumFolder(track ApiClients.Track, candidates []Candidate) []Candidate {
+	album := normalize(track.Album)
+	inAlbum := func(candidate Candidate) bool {
+		index := strings.LastIndex(candidate.Filename, "\\")
+		return index >= 0 && strings.Contains(normalize(candidate.Filename[:index]), album)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return inAlbum(candidates[i]) && !inAlbum(candidates[j])
+	})
+
+	return candidates
+}
+
+// downloadAlbumFolder queues every other audio file in the folder of the chosen
+// candidate and remembers the folder for the rest of the album's playlist tracks.
+// slskd saves them in a folder named after the remote one, next to the track.
+func downloadAlbumFolder(ctx context.Context, soulseek ApiClients.Soulseek, track ApiClients.Track, candidate Candidate) {
+	if _, ok := loadAlbumFolders()[albumKey(track)]; ok {
+		return
+	}
+	directory, ok := browseFolder(ctx, soulseek, candidate.Username, candidate.Filename)
+	if !ok {
+		return
+	}
+
+	folder := AlbumFolder{Username: candidate.Username, Directory: directory.Name}
+	for _, file := range directory.Files {
+		if !verifiableFile(file.Filename) {
+			continue
+		}
+		file.Filename = directory.Name + "\\" + file.Filename
+		folder.Files = append(folder.Files, file)
+		if file.Filename == candidate.Filename {
+			continue
+		}
+		err := startTransfer(ctx, soulseek, candidate.Username, file.Filename, file.Size)
+		if err != nil {
+			fmt.Printf("Could not download '%s': %s\n", file.Filename, err)
+		}
+	}
+
+	fmt.Printf("Downloading %d files of '%s' from %s\n", len(folder.Files), track.Album, candidate.Username)
+	saveAlbumFolder(albumKey(track), folder)
+}
+
+// takeFromAlbumFolder watches the transfer of the track's file when its album folder
+// was queued already. It returns false when the track has to be searched for, because
+// its album is not flagged, not queued yet or its file in the folder was tried before.
+func takeFromAlbumFolder(ctx context.Context, track ApiClients.Track, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) bool {
+	if !wantsAlbum(track) {
+		return false
+	}
+	folder, ok := loadAlbumFolders()[albumKey(track)]
+	if !ok {
+		return false
+	}
+
+	query := primaryArtist(track).Query()
+	best := Candidate{Score: -1}
+	for _, file := range folder.Files {
+		score := matcher.Score(query, track.Duration, Matcher.Candidate{Filename: file.Filename, Length: time.Duration(file.Length) * time.Second})
+		if score > best.Score {
+			best = Candidate{folder.Username, file.Filename, file.Size, score}
+		}
+	}
+	if best.Score < matchThreshold {
+		return false
+	}
+	entry, _ := history.Get(track.Query())
+	for _, tried := range entry.Tried {
+		if tried.Username == best.Username && tried.Filename == best.Filename {
+			return false
+		}
+	}
+
+	fmt.Printf("Taking '%s' from the album folder %s\n", track.Query(), folder.Directory)
+	history.MarkSelected(track.Query(), best)
+	history.StartTransfer(Transfer{track, best})
+	go observeTransfer(ctx, track, best.Username, best.Filename, best.Size, soulseek, queue)
+	return true
+}
+
+func loadAlbumFolders() map[string]AlbumFolder {
+	albumMutex.Lock()
+	defer albumMutex.Unlock()
+
+	folders := make(map[string]AlbumFolder)
+	contents, err := os.ReadFile(albumFoldersFile)
+	if err != nil {
+		return folders
+	}
+	json.Unmarshal(contents, &folders)
+
+	return folders
+}
+
+func saveAlbumFolder(key string, folder AlbumFolder) {
+	albumMutex.Lock()
+	defer albumMutex.Unlock()
+
+	folders := make(map[string]AlbumFolder)
+	contents, err := os.ReadFile(albumFoldersFile)
+	if err == nil {
+		json.Unmarshal(contents, &folders)
+	}
+	folders[key] = folder
+
+	contents, err = json.MarshalIndent(folders, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	os.WriteFile(albumFoldersFile, contents, 0666)
+}
+
+// runAlbum flags an album for full download, or prints the flagged albums when called
+// without arguments.
+func runAlbum(args []string) int {
+	if len(args) == 0 {
+		for _, entry := range readAlbumList() {
+			fmt.Println(entry)
+		}
+		return 0
+	}
+	if len(args) != 1 || !strings.Contains(args[0], " - ") {
+		fmt.Println("Usage: album \"<artist> - <album>\"")
+		return 2
+	}
+
+	file, err := os.OpenFile(albumFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+	fmt.Fprintln(file, args[0])
+
+	fmt.Printf("Playlist tracks of '%s' will download the whole album\n", args[0])
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"os"
+	"strings"
+)
+
+// aliasFile lists artist names that are shared under another spelling, one
+// "<name> = <alias>" per line, e.g. "P!nk = Pink" or a romanized Japanese name.
+// It is read on every use so edits apply to the running pipeline.
+const aliasFile = "aliases"
+
+func readAliases() map[string]string {
+	contents, err := os.ReadFile(aliasFile)
+	if err != nil {
+		return nil
+	}
+
+	aliases := make(map[string]string)
+	for _, line := range strings.Split(string(contents), "\n") {
+		name, alias, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(name) != "" && strings.TrimSpace(alias) != "" {
+			aliases[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(alias)
+		}
+	}
+
+	return aliases
+}
+
+// withAliases returns the track with its artists replaced by their aliases, and
+// whether any artist has one.
+func withAliases(track ApiClients.Track) (ApiClients.Track, bool) {
+	aliases := readAliases()
+	changed := false
+	artists := make([]string, len(track.Artists))
+	for i, artist := range track.Artists {
+		artists[i] = artist
+		if alias, ok := aliases[strings.ToLower(artist)]; ok {
+			artists[i] = alias
+			changed = true
+		}
+	}
+	track.Artists = artists
+
+	return track, changed
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// watchSince is when watch-artist started watching. Releases before it are only
+// downloaded with --backfill, later ones are recognized by the albums already seen,
+// so releases from while spotiseek was not running are downloaded too.
+var watchSince time.Time
+
+// setupWatchArtist points SOURCE at the releases of an artist, after which the
+// regular pipeline downloads the tracks of every new release. It returns a non-zero
+// exit code when the arguments are invalid.
+func setupWatchArtist(args []string) int {
+	flags := flag.NewFlagSet("watch-artist", flag.ContinueOnError)
+	only := flags.String("only", "", "watch only singles or albums")
+	backfill := flags.Bool("backfill", false, "also download the earlier releases")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || (*only != "" && *only != "singles" && *only != "albums") {
+		fmt.Println("Usage: watch-artist [--only singles|albums] [--backfill] <artist url or id>")
+		return 2
+	}
+
+	id := flags.Arg(0)
+	parsed, err := url.Parse(id)
+	if err == nil && parsed.Host != "" {
+		id = parsed.Path[strings.LastIndex(parsed.Path, "/")+1:]
+	}
+	spec := "artist:" + id
+	if *only != "" {
+		spec += ":" + *only
+	}
+
+	os.Setenv("SOURCE", spec)
+	if !*backfill {
+		watchSince = time.Now()
+	}
+	return 0
+}
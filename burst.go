@@ -0,0 +1,131 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Events"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// burstFile holds the queries of a mass import waiting for approve-burst.
+const burstFile = "burst"
+
+// enqueueTracks feeds tracks to the search queue, spacing them out by burstPacing
+// when a playlist check returns at least burstThreshold of them. Paced tracks are fed
+// in the background, since that takes hours and polling has to go on meanwhile.
+func enqueueTracks(ctx context.Context, queue chan ApiClients.Track, tracks []ApiClients.Track) {
+	if burstThreshold > 0 && len(tracks) >= burstThreshold {
+		fmt.Printf("%d tracks arrived at once, queueing one every %s\n", len(tracks), burstPacing)
+		go feedTracks(ctx, queue, tracks, burstPacing)
+		return
+	}
+
+	feedTracks(ctx, queue, tracks, 0)
+}
+
+// feedTracks sends tracks to the queue pacing apart. Tracks left over when ctx is
+// cancelled or shutdown begins are kept for the next start.
+func feedTracks(ctx context.Context, queue chan ApiClients.Track, tracks []ApiClients.Track, pacing time.Duration) {
+	for i := range tracks {
+		if pacing > 0 && i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-draining:
+			case <-time.After(pacing):
+			}
+		}
+
+		if ctx.Err() != nil || isDraining() {
+			for _, track := range tracks[i:] {
+				keepPending(track)
+			}
+			return
+		}
+		if !sendToQueue(ctx, queue, tracks[i]) {
+			for _, track := range tracks[i+1:] {
+				keepPending(track)
+			}
+			return
+		}
+	}
+}
+
+// unseenTracks drops tracks the history already has an outcome for, which a check
+// returns again after a restart without a readable timestamp file. Downloaded tracks
+// are always dropped, others only unless they were added again after that outcome,
+// like a removed track put back on the playlist.
+func unseenTracks(tracks []ApiClients.Track) []ApiClients.Track {
+	var unseen []ApiClients.Track
+	for _, track := range tracks {
+		entry, ok := history.Get(track.Query())
+		if ok && (entry.State == StateDownloaded || (entry.State != StateQueued && !track.AddedAt.After(entry.UpdatedAt))) {
+			continue
+		}
+		unseen = append(unseen, track)
+	}
+
+	return unseen
+}
+
+// holdBurst parks a mass import until the user confirms it. It reports whether the
+// tracks were held back.
+func holdBurst(tracks []ApiClients.Track) bool {
+	if burstConfirm <= 0 || len(tracks) < burstConfirm {
+		return false
+	}
+
+	held := make(map[string]bool)
+	contents, _ := os.ReadFile(burstFile)
+	for _, line := range strings.Split(string(contents), "\n") {
+		held[strings.TrimSpace(line)] = true
+	}
+
+	file, err := os.OpenFile(burstFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	for _, track := range tracks {
+		if !held[track.Query()] {
+			fmt.Fprintln(file, track.Query())
+		}
+	}
+
+	message := fmt.Sprintf("%d tracks were added at once, run 'approve-burst' to download them", len(tracks))
+	fmt.Println(message)
+	events.Publish(Events.BurstHeld, "", message)
+	return true
+}
+
+// runApproveBurst releases held tracks to the running pipeline through the redownload file.
+func runApproveBurst() int {
+	contents, err := os.ReadFile(burstFile)
+	if os.IsNotExist(err) {
+		fmt.Println("No tracks are waiting for approval")
+		return 0
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	file, err := os.OpenFile(redownloadFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+
+	_, err = file.Write(contents)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	os.Remove(burstFile)
+
+	fmt.Println("Held tracks will be queued on the next playlist check")
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Events"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Candidate is a file a search turned up for a track. The runners-up of a search
+// are kept in the history and tried in turn before searching again.
+type Candidate struct {
+	Username string  `json:"username"`
+	Filename string  `json:"filename"`
+	Size     int     `json:"size"`
+	Score    float64 `json:"score"`
+}
+
+// Transfer is a download handed over to slskd. It is kept in the history while it
+// runs, so a restart watches it to its end instead of losing it.
+type Transfer struct {
+	Track ApiClients.Track `json:"track"`
+	Candidate
+}
+
+// resumeTransfers watches the transfers that were still running at the last shutdown.
+func resumeTransfers(ctx context.Context, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) {
+	for _, transfer := range history.Transfers() {
+		fmt.Printf("Resuming the transfer of '%s'\n", transfer.Track.Query())
+		go observeTransfer(ctx, transfer.Track, transfer.Username, transfer.Filename, transfer.Size, soulseek, queue)
+	}
+}
+
+// downloadCandidate asks slskd to download the file and watches the transfer. While
+// downloads are paused the track is kept for the next playlist check instead.
+func downloadCandidate(ctx context.Context, track ApiClients.Track, candidate Candidate, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) {
+	err := startTransfer(ctx, soulseek, candidate.Username, candidate.Filename, candidate.Size)
+	if errors.Is(err, errDownloadsPaused) {
+		fmt.Printf("Not downloading '%s' for now, %s\n", track.Query(), err)
+		keepPending(track)
+		return
+	}
+	history.MarkSelected(track.Query(), candidate)
+	events.Publish(Events.MatchSelected, track.Query(), candidate.Username+": "+candidate.Filename)
+	if err != nil {
+		downloadFailed(ctx, track, err, soulseek, queue)
+		return
+	}
+
+	history.StartTransfer(Transfer{track, candidate})
+	if wantsAlbum(track) {
+		downloadAlbumFolder(ctx, soulseek, track, candidate)
+	}
+	downloadFolderExtras(ctx, soulseek, candidate.Username, candidate.Filename)
+	go observeTransfer(ctx, track, candidate.Username, candidate.Filename, candidate.Size, soulseek, queue)
+}
+
+// downloadFailed moves on to the next candidate of the last search when a chosen file
+// could not be downloaded, and only searches again once none is left.
+func downloadFailed(ctx context.Context, track ApiClients.Track, reason error, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) {
+	if slskdUnavailable(reason) {
+		failDownload(ctx, track, reason, queue)
+		return
+	}
+	next, ok := history.NextCandidate(track.Query())
+	if !ok {
+		failDownload(ctx, track, reason, queue)
+		return
+	}
+
+	failed := history.MarkCandidateFailed(track.Query(), reason.Error())
+	fmt.Printf("Download of '%s' failed (candidate %d): %s, trying %s's copy\n", track.Query(), failed, reason, next.Username)
+	events.Publish(Events.DownloadFailed, track.Query(), reason.Error())
+	downloadCandidate(ctx, track, next, soulseek, queue)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// waitForCapacity holds back new searches while downloading more would overload
+// slskd or the disk, and resumes once every limit is satisfied again. It returns
+// false when ctx was cancelled or shutdown began while waiting.
+func waitForCapacity(ctx context.Context, soulseek ApiClients.Soulseek) bool {
+	paused := ""
+	for {
+		reason := capacityProblem(ctx, soulseek)
+		pausedBy.Store(reason)
+		if reason == "" {
+			if paused != "" {
+				fmt.Println("Resuming searches")
+			}
+			return true
+		}
+		if reason != paused {
+			fmt.Printf("Pausing searches: %s\n", reason)
+			paused = reason
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-draining:
+			return false
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// pausedBy is why searches are held back right now, empty while they run.
+var pausedBy atomic.Value
+
+// capacityProblem explains why no new search should start, or returns "" when
+// there is room for one.
+func capacityProblem(ctx context.Context, soulseek ApiClients.Soulseek) string {
+	if problem := downloadProblem(ctx); problem != "" {
+		return problem
+	}
+	if problem := slskdProblem(ctx, soulseek); problem != "" {
+		return problem
+	}
+
+	if maxActiveTransfers > 0 {
+		users, err := soulseek.GetAllDownloads(ctx)
+		if err != nil {
+			return fmt.Sprintf("could not count the active transfers: %s", err)
+		}
+		active := 0
+		for _, user := range users {
+			active += user.CountActive()
+		}
+		if active >= maxActiveTransfers {
+			return fmt.Sprintf("%d active transfers", active)
+		}
+	}
+
+	return ""
+}
+
+// downloadProblem explains why no download may start, because the download gate is
+// closed or the disk is full, or returns "" when downloads may start.
+func downloadProblem(ctx context.Context) string {
+	if problem := gateProblem(ctx); problem != "" {
+		return problem
+	}
+
+	if diskQuota > 0 {
+		used := downloadDirSize()
+		if used >= diskQuota {
+			return fmt.Sprintf("download directory uses %d of %d MiB", used>>20, diskQuota>>20)
+		}
+	}
+
+	if minFreeSpace > 0 {
+		free, err := freeSpace(os.Getenv("SLSKD_DOWNLOAD_DIR"))
+		if err == nil && free < minFreeSpace {
+			return fmt.Sprintf("only %d MiB free on the download volume", free>>20)
+		}
+	}
+
+	return ""
+}
+
+// startTransfer asks slskd to download a file unless downloadProblem forbids it,
+// which is reported as errDownloadsPaused. Every Soulseek download starts here.
+func startTransfer(ctx context.Context, soulseek ApiClients.Soulseek, username string, filename string, size int) error {
+	if problem := downloadProblem(ctx); problem != "" {
+		return fmt.Errorf("%w: %s", errDownloadsPaused, problem)
+	}
+
+	_, err := soulseek.Transfer(ctx, username, filename, size)
+	return err
+}
+
+var errDownloadsPaused = errors.New("downloads are paused")
+
+var dirSizeCache struct {
+	mutex     sync.Mutex
+	size      int64
+	checkedAt time.Time
+}
+
+// downloadDirSize walks SLSKD_DOWNLOAD_DIR at most once a minute.
+func downloadDirSize() int64 {
+	dirSizeCache.mutex.Lock()
+	defer dirSizeCache.mutex.Unlock()
+
+	if time.Since(dirSizeCache.checkedAt) < time.Minute {
+		return dirSizeCache.size
+	}
+
+	var size int64
+	filepath.WalkDir(os.Getenv("SLSKD_DOWNLOAD_DIR"), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	dirSizeCache.size = size
+	dirSizeCache.checkedAt = time.Now()
+	return size
+}
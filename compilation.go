@@ -0,0 +1,31 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"regexp"
+	"strings"
+)
+
+// compilationPrefix matches what compilation rips put in front of the actual
+// "<artist> - <title>": "VA - ", "Various Artists - " and track numbers like
+// "01 ", "01. ", "1-03 - " or "CD2 07 ".
+var compilationPrefix = regexp.MustCompile(`(?i)^((va|various( artists)?)\s*-\s*|(cd\s*)?\d{1,2}([-.]\d{1,2})?[\s.)_-]+)+`)
+
+// primaryArtist keeps only the first artist of tracks from compilations, the others
+// are rarely in the file names and only lower the score.
+func primaryArtist(track ApiClients.Track) ApiClients.Track {
+	if track.Compilation && len(track.Artists) > 1 {
+		track.Artists = track.Artists[:1]
+	}
+
+	return track
+}
+
+// compilationFilename strips compilation prefixes from the file name at the end of
+// a Soulseek path, which separates directories with backslashes. Artists starting
+// with a number lose it too, so the result is scored next to the full name.
+func compilationFilename(filename string) string {
+	cut := strings.LastIndexAny(filename, `\/`) + 1
+
+	return filename[:cut] + compilationPrefix.ReplaceAllString(filename[cut:], "")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// IndexRecord is one line of the download index shared by all playlists, kept as
+// JSON lines so several processes can append to it safely.
+type IndexRecord struct {
+	TrackId  string `json:"trackId,omitempty"`
+	Key      string `json:"key"`
+	Filename string `json:"filename"`
+}
+
+// normalizedKey reduces a track to lowercase letters and digits of its artists and title.
+func normalizedKey(track ApiClients.Track) string {
+	return normalize(track.Query())
+}
+
+// reuseExistingDownload reports whether the track is already on disk, either for this
+// playlist according to the history, for another one according to DOWNLOAD_INDEX,
+// in which case the file is linked or copied into this playlist's download folder,
+// or in the user's library.
+func reuseExistingDownload(track ApiClients.Track) bool {
+	entry, ok := history.Get(track.Query())
+	if ok && entry.State == StateDownloaded && fileExists(entry.Filename) {
+		fmt.Printf("'%s' is already downloaded\n", track.Query())
+		return true
+	}
+
+	record, ok := findInIndex(track)
+	if !ok {
+		return reuseFromLibrary(track)
+	}
+
+	target := filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), filepath.Base(filepath.Dir(record.Filename)), filepath.Base(record.Filename))
+	if target != record.Filename {
+		err := linkOrCopy(record.Filename, target)
+		if err != nil {
+			fmt.Printf("Could not reuse %s: %s\n", record.Filename, err)
+			return false
+		}
+	}
+
+	fmt.Printf("Reusing %s for '%s'\n", record.Filename, track.Query())
+	onDownloaded(track, target, "index")
+	return true
+}
+
+func findInIndex(track ApiClients.Track) (IndexRecord, bool) {
+	indexPath := os.Getenv("DOWNLOAD_INDEX")
+	if indexPath == "" {
+		return IndexRecord{}, false
+	}
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return IndexRecord{}, false
+	}
+	defer file.Close()
+
+	key := normalizedKey(track)
+	var found IndexRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record IndexRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if (track.ID != "" && record.TrackId == track.ID) || record.Key == key {
+			if fileExists(record.Filename) {
+				found = record
+			}
+		}
+	}
+
+	return found, found.Filename != ""
+}
+
+// addToIndex makes a finished download available to the other playlists.
+func addToIndex(track ApiClients.Track, path string) {
+	indexPath := os.Getenv("DOWNLOAD_INDEX")
+	if indexPath == "" {
+		return
+	}
+
+	line, err := json.Marshal(IndexRecord{TrackId: track.ID, Key: normalizedKey(track), Filename: path})
+	if err != nil {
+		panic(err)
+	}
+	file, err := os.OpenFile(indexPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Printf("Could not update the download index: %s\n", err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+// linkOrCopy hard links source to target and copies it when they are on different
+// volumes. A target that already is source is kept, any other existing target is
+// reported instead of overwritten.
+func linkOrCopy(source string, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0777)
+	if err != nil {
+		return err
+	}
+	if sameFile(source, target) {
+		return nil
+	}
+	if os.Link(source, target) == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+
+	return err
+}
+
+func sameFile(a string, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(infoA, infoB)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
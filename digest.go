@@ -0,0 +1,203 @@
+package main
+
+import (
+	"Spotiseek2/internal/Events"
+	"Spotiseek2/internal/Notifiers"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	htmlTemplate "html/template"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	textTemplate "text/template"
+	"time"
+)
+
+// digestFile keeps when the last digest was sent, so restarts do not send another.
+const digestFile = "digest"
+
+// Digest summarizes what happened to the playlist since the previous digest.
+type Digest struct {
+	Playlist   string
+	Since      time.Time
+	Discovered int64
+	Downloaded []HistoryEntry
+	Failed     []HistoryEntry
+	UsedBytes  int64
+	FreeBytes  uint64
+}
+
+func (d Digest) UsedMiB() int64 {
+	return d.UsedBytes >> 20
+}
+
+func (d Digest) FreeMiB() uint64 {
+	return d.FreeBytes >> 20
+}
+
+var digestText = textTemplate.Must(textTemplate.New("digest").Parse(`Since {{.Since.Format "2006-01-02 15:04"}}, {{.Discovered}} new tracks were found on '{{.Playlist}}'.
+
+Downloaded ({{len .Downloaded}}):
+{{range .Downloaded}}  {{.Query}}
+{{else}}  nothing
+{{end}}
+Needs attention ({{len .Failed}}):
+{{range .Failed}}  {{.Query}}: {{.Reason}}
+{{else}}  nothing
+{{end}}
+The downloads take {{.UsedMiB}} MiB, {{.FreeMiB}} MiB are free.
+`))
+
+var digestHTML = htmlTemplate.Must(htmlTemplate.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Playlist}}</h2>
+<p>Since {{.Since.Format "2006-01-02 15:04"}}, <b>{{.Discovered}}</b> new tracks were found.</p>
+<h3>Downloaded ({{len .Downloaded}})</h3>
+<ul>
+{{range .Downloaded}}<li>{{.Query}} <small>from {{.Source}}</small></li>
+{{else}}<li>nothing</li>
+{{end}}</ul>
+<h3>Needs attention ({{len .Failed}})</h3>
+<ul>
+{{range .Failed}}<li>{{.Query}}: <i>{{.Reason}}</i></li>
+{{else}}<li>nothing</li>
+{{end}}</ul>
+<p>The downloads take {{.UsedMiB}} MiB, {{.FreeMiB}} MiB are free.</p>
+</body>
+</html>
+`))
+
+// digestState is kept in the digest file, so restarts neither send another digest nor
+// lose the tracks found since the last one.
+type digestState struct {
+	SentAt     time.Time `json:"sentAt"`
+	Discovered int64     `json:"discovered"`
+}
+
+var digests struct {
+	mutex sync.Mutex
+	state digestState
+}
+
+// startDigests counts the tracks found on the playlist and sends a digest through
+// notifier whenever interval passed since the previous one, until ctx is cancelled.
+// It has to be called before the first playlist check to count its tracks.
+func startDigests(ctx context.Context, notifier Notifiers.Notifier, interval time.Duration) {
+	digests.state = loadDigestState()
+	saveDigestState()
+	events.Subscribe(func(event Events.Event) {
+		if event.Type != Events.TrackDiscovered {
+			return
+		}
+		digests.mutex.Lock()
+		defer digests.mutex.Unlock()
+		digests.state.Discovered++
+		saveDigestState()
+	})
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			digests.mutex.Lock()
+			state := digests.state
+			digests.mutex.Unlock()
+			if time.Since(state.SentAt) < interval {
+				continue
+			}
+
+			err := sendDigest(notifier, buildDigest(state))
+			digests.mutex.Lock()
+			if err != nil {
+				fmt.Printf("Could not send the digest: %s\n", err)
+				digests.state.SentAt = digests.state.SentAt.Add(time.Hour)
+			} else {
+				digests.state = digestState{SentAt: time.Now(), Discovered: digests.state.Discovered - state.Discovered}
+			}
+			saveDigestState()
+			digests.mutex.Unlock()
+		}
+	}()
+}
+
+// loadDigestState reads the digest file, which held only the time of the last
+// digest in earlier versions. Without one the first digest is due after an interval.
+func loadDigestState() digestState {
+	state := digestState{SentAt: time.Now()}
+	contents, err := os.ReadFile(digestFile)
+	if err != nil {
+		return state
+	}
+	if json.Unmarshal(contents, &state) == nil {
+		return state
+	}
+	if sent, err := time.Parse(time.RFC3339, strings.TrimSpace(string(contents))); err == nil {
+		state.SentAt = sent
+	}
+
+	return state
+}
+
+// saveDigestState writes digests.state, the caller holds digests.mutex.
+func saveDigestState() {
+	contents, err := json.Marshal(digests.state)
+	if err != nil {
+		panic(err)
+	}
+	os.WriteFile(digestFile, contents, 0666)
+}
+
+func buildDigest(state digestState) Digest {
+	since := state.SentAt
+	digest := Digest{Playlist: playlistName, Since: since, Discovered: state.Discovered}
+	for _, entry := range history.UpdatedSince(since) {
+		switch entry.State {
+		case StateDownloaded:
+			digest.Downloaded = append(digest.Downloaded, entry)
+		case StateFailed, StateWishlisted:
+			digest.Failed = append(digest.Failed, entry)
+		}
+	}
+	sort.Slice(digest.Downloaded, func(i, j int) bool {
+		return digest.Downloaded[i].UpdatedAt.Before(digest.Downloaded[j].UpdatedAt)
+	})
+	sort.Slice(digest.Failed, func(i, j int) bool {
+		return digest.Failed[i].UpdatedAt.Before(digest.Failed[j].UpdatedAt)
+	})
+
+	for _, entry := range history.Downloaded() {
+		if info, err := os.Stat(entry.Filename); err == nil {
+			digest.UsedBytes += info.Size()
+		}
+	}
+	digest.FreeBytes, _ = freeSpace(os.Getenv("SLSKD_DOWNLOAD_DIR"))
+
+	return digest
+}
+
+func sendDigest(notifier Notifiers.Notifier, digest Digest) error {
+	var text, html bytes.Buffer
+	err := digestText.Execute(&text, digest)
+	if err != nil {
+		return err
+	}
+	err = digestHTML.Execute(&html, digest)
+	if err != nil {
+		return err
+	}
+
+	return notifier.Notify(Notifiers.Notification{
+		Title: fmt.Sprintf("spotiseek: %d downloaded, %d need attention", len(digest.Downloaded), len(digest.Failed)),
+		Text:  text.String(),
+		HTML:  html.String(),
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// freeSpace returns the bytes available to unprivileged users on the volume holding path.
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
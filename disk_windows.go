@@ -0,0 +1,24 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func freeSpace(path string) (uint64, error) {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+	getDiskFreeSpaceEx := kernel32.NewProc("GetDiskFreeSpaceExW")
+
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var available uint64
+	result, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&available)), 0, 0)
+	if result == 0 {
+		return 0, err
+	}
+
+	return available, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const minimumFreeSpace = 1 << 30
+
+// runDoctor validates the environment and prints a fix for every failed check.
+// It returns the process exit code.
+func runDoctor() int {
+	failures := 0
+	check := func(name string, fix string, test func() error) bool {
+		err := func() (err error) {
+			// the playlist source clients panic on errors, which is a failed check here
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("%v", r)
+				}
+			}()
+			return test()
+		}()
+
+		if err != nil {
+			failures++
+			fmt.Printf("[FAIL] %s: %s\n       %s\n", name, err, fix)
+			return false
+		}
+		fmt.Printf("[ OK ] %s\n", name)
+		return true
+	}
+
+	playlist := os.Getenv("SOURCE")
+	if playlist == "" {
+		playlist = os.Getenv("SPOTIFY_PLAYLIST_ID")
+	}
+
+	check("playlist configured", "set SOURCE to a playlist URL or SPOTIFY_PLAYLIST_ID to a Spotify playlist id", func() error {
+		if playlist == "" {
+			return fmt.Errorf("neither SOURCE nor SPOTIFY_PLAYLIST_ID is set")
+		}
+		return nil
+	})
+
+	sourceUsable := true
+	if isSpotifySource(playlist) {
+		sourceUsable = check("Spotify credentials", "check SPOTIFY_ID and SPOTIFY_SECRET against https://developer.spotify.com/dashboard", func() error {
+			return ApiClients.CheckSpotifyCredentials(os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"))
+		})
+	}
+	if playlist != "" && sourceUsable {
+		check("playlist readable", "make sure the playlist exists, is public and the provider credentials are set", func() error {
+			source, sourceId := ApiClients.DetectSource(playlist)
+			fmt.Printf("       found '%s'\n", source.GetPlaylist(context.Background(), sourceId).Name)
+			return nil
+		})
+	}
+
+	check("slskd reachable and logged in", "set SLSKD_URL to the slskd web address and check its Soulseek username/password", func() error {
+		if os.Getenv("SLSKD_URL") == "" {
+			return fmt.Errorf("SLSKD_URL is not set")
+		}
+		state, err := ApiClients.NewSoulseek(os.Getenv("SLSKD_URL")).GetServerState(context.Background())
+		if err != nil {
+			return err
+		}
+		if !state.IsLoggedIn {
+			return fmt.Errorf("slskd reports '%s'", state.State)
+		}
+		return nil
+	})
+
+	downloadDir := os.Getenv("SLSKD_DOWNLOAD_DIR")
+	check("download directory writable", "set SLSKD_DOWNLOAD_DIR to the host path of slskd's downloads directory", func() error {
+		if downloadDir == "" {
+			return fmt.Errorf("SLSKD_DOWNLOAD_DIR is not set")
+		}
+		probe, err := os.CreateTemp(downloadDir, ".doctor")
+		if err != nil {
+			return err
+		}
+		probe.Close()
+		return os.Remove(probe.Name())
+	})
+
+	check("free disk space", "free up space on the volume holding SLSKD_DOWNLOAD_DIR", func() error {
+		free, err := freeSpace(filepath.Clean(downloadDir))
+		if err != nil {
+			return err
+		}
+		if free < minimumFreeSpace {
+			return fmt.Errorf("only %d MiB left", free>>20)
+		}
+		return nil
+	})
+
+	if os.Getenv("FALLBACK") != "" {
+		check("fallback command", "install yt-dlp or point FALLBACK_COMMAND at it", func() error {
+			command := strings.Fields(os.Getenv("FALLBACK_COMMAND") + " yt-dlp")[0]
+			_, err := exec.LookPath(command)
+			return err
+		})
+	}
+
+	if failures > 0 {
+		fmt.Printf("%d check(s) failed\n", failures)
+		return 1
+	}
+	fmt.Println("Everything looks fine")
+	return 0
+}
+
+func isSpotifySource(playlist string) bool {
+	if playlist == "" || strings.HasPrefix(playlist, "lastfm:") {
+		return false
+	}
+
+	return !strings.Contains(playlist, "deezer.com") && !strings.Contains(playlist, "tidal.com")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// draining is closed on shutdown: no new tracks are searched for and tracks that
+// were about to be queued are kept for the next start instead.
+var draining = make(chan struct{})
+
+// inFlight counts searches that have not handed their download over to slskd yet.
+var inFlight sync.WaitGroup
+
+// drainMutex orders closing draining against new searches joining inFlight, so drain
+// never waits while a search is still being added.
+var drainMutex sync.Mutex
+
+// startSearch counts a search as in flight, or returns false once shutdown began.
+// Every successful call is paired with inFlight.Done.
+func startSearch() bool {
+	drainMutex.Lock()
+	defer drainMutex.Unlock()
+
+	if isDraining() {
+		return false
+	}
+	inFlight.Add(1)
+	return true
+}
+
+// pendingFile keeps tracks that never reached the search loop, one JSON encoded track
+// per line, so the next start queues them as they were.
+const pendingFile = "pending"
+
+// pendingMutex guards appending to and taking the pending and redownload files.
+var pendingMutex sync.Mutex
+
+// keepPending appends a track that never reached the search loop to the pending
+// file. Unlike a redownload its history, and so its attempt count, is left alone.
+func keepPending(track ApiClients.Track) {
+	pendingMutex.Lock()
+	defer pendingMutex.Unlock()
+
+	line, err := json.Marshal(track)
+	if err != nil {
+		panic(err)
+	}
+	file, err := os.OpenFile(pendingFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Printf("Could not keep '%s' for the next start: %s\n", track.Query(), err)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+// queuePending puts the tracks kept by keepPending back into the pipeline.
+func queuePending(ctx context.Context, queue chan ApiClients.Track) {
+	var tracks []ApiClients.Track
+	for _, line := range takeLines(pendingFile) {
+		var track ApiClients.Track
+		if json.Unmarshal([]byte(line), &track) != nil {
+			continue
+		}
+		tracks = append(tracks, track)
+	}
+	enqueueTracks(ctx, queue, tracks)
+}
+
+func isDraining() bool {
+	select {
+	case <-draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain stops taking tracks from the queue and waits up to timeout for running
+// searches to hand their downloads over to slskd, which finishes them on its own.
+func drain(timeout time.Duration) {
+	drainMutex.Lock()
+	close(draining)
+	drainMutex.Unlock()
+	fmt.Println("Waiting for running searches to finish")
+
+	finished := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		fmt.Printf("Searches still running after %s, stopping anyway\n", timeout)
+	}
+
+	// wait for tracks being written to the pending file
+	pendingMutex.Lock()
+	pendingMutex.Unlock()
+}
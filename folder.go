@@ -0,0 +1,139 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// folderCandidates is how many of the best ranked files get their folder browsed,
+// every browse is a round trip to the peer.
+const folderCandidates = 5
+
+var coverExtensions = []string{".jpg", ".jpeg", ".png"}
+
+// preferCompleteFolders browses the folders of the best candidates that share their
+// score with another one and moves files from folders with cover art and a consistent
+// bitrate ahead of equally scored ones. A better match always stays ahead.
+func preferCompleteFolders(ctx context.Context, soulseek ApiClients.Soulseek, ranked []rankedFile) []rankedFile {
+	count := 0
+	for count < len(ranked) && count < folderCandidates && ranked[count].score >= matchThreshold {
+		count++
+	}
+
+	completeness := make([]int, count)
+	for i := 0; i < count; i++ {
+		tied := (i > 0 && ranked[i-1].score == ranked[i].score) || (i+1 < count && ranked[i+1].score == ranked[i].score)
+		if !tied {
+			continue
+		}
+		directory, ok := browseFolder(ctx, soulseek, ranked[i].response.Username, ranked[i].file.Filename)
+		if !ok {
+			continue
+		}
+		if containsExtension(directory, coverExtensions) {
+			completeness[i]++
+		}
+		if consistentBitrate(directory) {
+			completeness[i]++
+		}
+	}
+
+	top := append([]rankedFile(nil), ranked[:count]...)
+	order := make([]int, count)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if top[a].score != top[b].score {
+			return top[a].score > top[b].score
+		}
+		return completeness[a] > completeness[b]
+	})
+	for i, index := range order {
+		ranked[i] = top[index]
+	}
+
+	return ranked
+}
+
+// downloadFolderExtras queues the files next to a download whose extension is listed
+// in FOLDER_EXTRAS, such as the cue sheet and rip log of a lossless release.
+func downloadFolderExtras(ctx context.Context, soulseek ApiClients.Soulseek, username string, filename string) {
+	if len(folderExtras) == 0 {
+		return
+	}
+
+	directory, ok := browseFolder(ctx, soulseek, username, filename)
+	if !ok {
+		return
+	}
+	for _, file := range directory.Files {
+		if !hasExtension(file.Filename, folderExtras) {
+			continue
+		}
+		err := startTransfer(ctx, soulseek, username, directory.Name+"\\"+file.Filename, file.Size)
+		if err != nil {
+			fmt.Printf("Could not download '%s': %s\n", file.Filename, err)
+		}
+	}
+}
+
+// browseFolder returns the folder a remote file lives in, peers that are offline or
+// refuse browsing are reported and skipped.
+func browseFolder(ctx context.Context, soulseek ApiClients.Soulseek, username string, filename string) (ApiClients.Directory, bool) {
+	index := strings.LastIndex(filename, "\\")
+	if index < 0 {
+		return ApiClients.Directory{}, false
+	}
+	directory, err := soulseek.BrowseDirectory(ctx, username, filename[:index])
+	if err != nil {
+		fmt.Printf("Could not browse the folder of '%s': %s\n", filename, err)
+		return ApiClients.Directory{}, false
+	}
+	if directory.Name == "" {
+		directory.Name = filename[:index]
+	}
+
+	return directory, true
+}
+
+func containsExtension(directory ApiClients.Directory, extensions []string) bool {
+	for _, file := range directory.Files {
+		if hasExtension(file.Filename, extensions) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasExtension(filename string, extensions []string) bool {
+	for _, extension := range extensions {
+		if strings.HasSuffix(strings.ToLower(filename), extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// consistentBitrate reports whether all audio files of a folder share one bitrate,
+// which mixed up or partially transcoded folders usually do not.
+func consistentBitrate(directory ApiClients.Directory) bool {
+	bitrate := 0
+	for _, file := range directory.Files {
+		if file.BitRate == 0 {
+			continue
+		}
+		if bitrate != 0 && file.BitRate != bitrate {
+			return false
+		}
+		bitrate = file.BitRate
+	}
+
+	return bitrate != 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gateCommand and gateURL must succeed before a download starts, e.g. a script
+// checking that the VPN is up or an endpoint reporting a metered connection.
+var gateCommand []string
+var gateURL string
+
+func loadGateConfig() {
+	gateCommand = strings.Fields(os.Getenv("DOWNLOAD_GATE_COMMAND"))
+	gateURL = os.Getenv("DOWNLOAD_GATE_URL")
+}
+
+// gateProblem explains why the gate keeps downloads closed, or returns "" when
+// every configured check succeeded.
+func gateProblem(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if len(gateCommand) > 0 {
+		output, err := exec.CommandContext(ctx, gateCommand[0], gateCommand[1:]...).CombinedOutput()
+		if err != nil {
+			return strings.TrimSpace(fmt.Sprintf("download gate command failed: %s %s", err, output))
+		}
+	}
+
+	if gateURL != "" {
+		request, err := http.NewRequestWithContext(ctx, "GET", gateURL, nil)
+		if err != nil {
+			return fmt.Sprintf("download gate URL is invalid: %s", err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return fmt.Sprintf("download gate URL is unreachable: %s", err)
+		}
+		response.Body.Close()
+		if response.StatusCode >= 300 {
+			return fmt.Sprintf("download gate URL responded with HTTP %s", response.Status)
+		}
+	}
+
+	return ""
+}
@@ -2,12 +2,16 @@ module Spotiseek2
 
 go 1.20
 
+require (
+	github.com/mozillazg/go-unidecode v0.2.0
+	github.com/zmb3/spotify/v2 v2.3.1
+	golang.org/x/oauth2 v0.0.0-20210810183815-faf39c7919d5
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/golang/protobuf v1.5.2 // indirect
-	github.com/zmb3/spotify v1.3.0 // indirect
-	github.com/zmb3/spotify/v2 v2.3.1 // indirect
 	golang.org/x/net v0.0.0-20210813160813-60bc85c4be6d // indirect
-	golang.org/x/oauth2 v0.0.0-20210810183815-faf39c7919d5 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.27.1 // indirect
 )
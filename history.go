@@ -0,0 +1,432 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StateDownloaded = "downloaded"
+	StateFailed     = "failed"
+	StateQueued     = "queued"
+	StateMissing    = "missing"
+	StateRemoved    = "removed"
+	StateWishlisted = "wishlisted"
+)
+
+type HistoryEntry struct {
+	Query            string            `json:"query"`
+	State            string            `json:"state"`
+	Attempts         int               `json:"attempts"`
+	FailedCandidates int               `json:"failedCandidates,omitempty"`
+	PreviousAttempts int               `json:"previousAttempts,omitempty"`
+	Reason           string            `json:"reason,omitempty"`
+	Filename         string            `json:"filename,omitempty"`
+	Source           string            `json:"source,omitempty"`
+	WishlistSearches int               `json:"wishlistSearches,omitempty"`
+	Artists          []string          `json:"artists,omitempty"`
+	Score            float64           `json:"score,omitempty"`
+	Candidates       []Candidate       `json:"candidates,omitempty"`
+	Tried            []Candidate       `json:"tried,omitempty"`
+	Rejected         []Candidate       `json:"rejected,omitempty"`
+	Transfer         *Transfer         `json:"transfer,omitempty"`
+	Track            *ApiClients.Track `json:"track,omitempty"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+}
+
+// History keeps the outcome of every track the pipeline has tried to download,
+// persisted as JSON next to the timestamp file.
+type History struct {
+	path    string
+	mutex   sync.Mutex
+	Entries map[string]*HistoryEntry `json:"entries"`
+}
+
+func LoadHistory(path string) *History {
+	h := &History{
+		path:    path,
+		Entries: make(map[string]*HistoryEntry),
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	err = json.Unmarshal(contents, h)
+	if err != nil {
+		panic(err)
+	}
+	if h.Entries == nil {
+		h.Entries = make(map[string]*HistoryEntry)
+	}
+
+	return h
+}
+
+func (h *History) entry(query string) *HistoryEntry {
+	entry, ok := h.Entries[query]
+	if !ok {
+		entry = &HistoryEntry{Query: query}
+		h.Entries[query] = entry
+	}
+
+	return entry
+}
+
+// Remember keeps the playlist entry of a track the first time it is processed, so
+// redownloads and wishlist searches do not have to list the playlist again.
+func (h *History) Remember(track ApiClients.Track) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(track.Query())
+	if entry.Track != nil {
+		return
+	}
+	entry.Track = &track
+	h.save()
+}
+
+// Get returns a copy of the entry recorded for query.
+func (h *History) Get(query string) (HistoryEntry, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry, ok := h.Entries[query]
+	if !ok {
+		return HistoryEntry{}, false
+	}
+
+	return *entry, true
+}
+
+// Find looks an entry up by its exact query or, failing that, by a case-insensitive
+// part of it that matches exactly one entry.
+func (h *History) Find(query string) (HistoryEntry, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if entry, ok := h.Entries[query]; ok {
+		return *entry, nil
+	}
+
+	var matches []*HistoryEntry
+	for key, entry := range h.Entries {
+		if strings.Contains(strings.ToLower(key), strings.ToLower(query)) {
+			matches = append(matches, entry)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return HistoryEntry{}, fmt.Errorf("no track matching '%s' in the history", query)
+	case 1:
+		return *matches[0], nil
+	}
+
+	return HistoryEntry{}, fmt.Errorf("'%s' matches %d tracks, be more specific", query, len(matches))
+}
+
+// Requeue clears the outcome of a track so it is downloaded again, keeping the attempt count.
+func (h *History) Requeue(query string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.State = StateQueued
+	entry.PreviousAttempts += entry.Attempts
+	entry.Attempts = 0
+	entry.Reason = ""
+	entry.Filename = ""
+	entry.Source = ""
+	entry.FailedCandidates = 0
+	entry.Candidates = nil
+	entry.UpdatedAt = time.Now()
+	h.save()
+}
+
+// Downloaded returns copies of all entries whose file should be on disk.
+func (h *History) Downloaded() []HistoryEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var downloaded []HistoryEntry
+	for _, entry := range h.Entries {
+		if entry.State == StateDownloaded {
+			downloaded = append(downloaded, *entry)
+		}
+	}
+
+	return downloaded
+}
+
+// UpdatedSince returns copies of the entries that changed after since.
+func (h *History) UpdatedSince(since time.Time) []HistoryEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var updated []HistoryEntry
+	for _, entry := range h.Entries {
+		if entry.UpdatedAt.After(since) {
+			updated = append(updated, *entry)
+		}
+	}
+
+	return updated
+}
+
+// MarkRemoved records that a track left the playlist and its file was moved to trashed.
+func (h *History) MarkRemoved(query string, trashed string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.State = StateRemoved
+	entry.Filename = trashed
+	entry.UpdatedAt = time.Now()
+	h.save()
+}
+
+// MarkMissingFiles flags downloaded tracks whose file no longer exists and returns their queries.
+func (h *History) MarkMissingFiles() []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var missing []string
+	for query, entry := range h.Entries {
+		if entry.State != StateDownloaded {
+			continue
+		}
+		if _, err := os.Stat(entry.Filename); !os.IsNotExist(err) {
+			continue
+		}
+
+		entry.State = StateMissing
+		entry.UpdatedAt = time.Now()
+		missing = append(missing, query)
+	}
+	if len(missing) > 0 {
+		h.save()
+	}
+
+	return missing
+}
+
+// MarkFailed records a failed attempt and returns how many attempts were made so far.
+// The artists are kept for the failure statistics unless none are given.
+func (h *History) MarkFailed(query string, artists []string, reason string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	if len(artists) > 0 {
+		entry.Artists = artists
+	}
+	entry.State = StateFailed
+	entry.Attempts++
+	entry.Reason = reason
+	entry.UpdatedAt = time.Now()
+	h.save()
+
+	return entry.Attempts
+}
+
+// MarkCandidateFailed records a failed download of a runner-up candidate and returns
+// how many candidates failed so far. It does not count as an attempt, the search
+// that found the candidates does.
+func (h *History) MarkCandidateFailed(query string, reason string) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.FailedCandidates++
+	entry.Reason = reason
+	entry.UpdatedAt = time.Now()
+	h.save()
+
+	return entry.FailedCandidates
+}
+
+// SetCandidates keeps the files to try when the chosen one cannot be downloaded.
+func (h *History) SetCandidates(query string, candidates []Candidate) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.Candidates = candidates
+	h.save()
+}
+
+// NextCandidate takes the best remaining candidate of the last search for query.
+func (h *History) NextCandidate(query string) (Candidate, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	for len(entry.Candidates) > 0 {
+		next := entry.Candidates[0]
+		entry.Candidates = entry.Candidates[1:]
+		h.save()
+		// histories written before ranking left out unverifiable files may hold some
+		if verifiableFile(next.Filename) {
+			return next, true
+		}
+	}
+
+	return Candidate{}, false
+}
+
+// MarkSelected records the match score of the file chosen for a track and keeps the
+// file among the last maxTried ones tried.
+func (h *History) MarkSelected(query string, candidate Candidate) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.Score = candidate.Score
+	entry.Tried = append(entry.Tried, candidate)
+	if len(entry.Tried) > maxTried {
+		entry.Tried = entry.Tried[len(entry.Tried)-maxTried:]
+	}
+	h.save()
+}
+
+// StartTransfer records a transfer handed over to slskd for the track.
+func (h *History) StartTransfer(transfer Transfer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(transfer.Track.Query())
+	entry.Transfer = &transfer
+	h.save()
+}
+
+// EndTransfer forgets the transfer of filename from username once it was watched to
+// its end. A transfer of the next candidate that started meanwhile is kept.
+func (h *History) EndTransfer(query string, username string, filename string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry, ok := h.Entries[query]
+	if !ok || entry.Transfer == nil || entry.Transfer.Username != username || entry.Transfer.Filename != filename {
+		return
+	}
+	entry.Transfer = nil
+	h.save()
+}
+
+// Transfers returns copies of the transfers that were not watched to their end.
+func (h *History) Transfers() []Transfer {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var transfers []Transfer
+	for _, entry := range h.Entries {
+		if entry.Transfer != nil {
+			transfers = append(transfers, *entry.Transfer)
+		}
+	}
+
+	return transfers
+}
+
+// maxTried bounds how many tried files a history entry keeps.
+const maxTried = 5
+
+// SetRejected keeps the best files the last search for query found below the threshold.
+func (h *History) SetRejected(query string, rejected []Candidate) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.Rejected = rejected
+	h.save()
+}
+
+func (h *History) MarkDownloaded(query string, filename string, source string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	if source != "soulseek" {
+		// only Soulseek downloads were chosen by the matcher
+		entry.Score = 0
+	}
+	entry.State = StateDownloaded
+	entry.Attempts++
+	entry.Reason = ""
+	entry.Filename = filename
+	entry.Source = source
+	entry.WishlistSearches = 0
+	entry.FailedCandidates = 0
+	entry.Candidates = nil
+	entry.Tried = nil
+	entry.Rejected = nil
+	entry.UpdatedAt = time.Now()
+	h.save()
+}
+
+// MarkWishlisted parks a track that nobody shares until the wishlist searches for it again.
+func (h *History) MarkWishlisted(query string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry := h.entry(query)
+	entry.State = StateWishlisted
+	entry.UpdatedAt = time.Now()
+	h.save()
+}
+
+// DueWishlist returns the wishlisted tracks that were last searched longer than interval
+// ago and counts the search. Tracks that used up maxSearches are marked as failed.
+func (h *History) DueWishlist(interval time.Duration, maxSearches int) []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var due []string
+	changed := false
+	for query, entry := range h.Entries {
+		if entry.State != StateWishlisted || time.Since(entry.UpdatedAt) < interval {
+			continue
+		}
+		if entry.WishlistSearches >= maxSearches {
+			entry.State = StateFailed
+			entry.Reason = fmt.Sprintf("no search results after %d wishlist searches", entry.WishlistSearches)
+			entry.UpdatedAt = time.Now()
+			changed = true
+			continue
+		}
+
+		entry.WishlistSearches++
+		due = append(due, query)
+		changed = true
+	}
+	if changed {
+		h.save()
+	}
+
+	return due
+}
+
+// save writes the history to a temporary file and renames it into place, so a crash
+// or a full disk while writing leaves the previous history intact.
+func (h *History) save() {
+	contents, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	temporary := h.path + ".tmp"
+	err = os.WriteFile(temporary, contents, 0666)
+	if err == nil {
+		err = os.Rename(temporary, h.path)
+	}
+	if err != nil {
+		os.Remove(temporary)
+		fmt.Printf("Could not save the history to %s: %s\n", h.path, err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// importFile hands the downloads found by runImport to the pipeline, which owns the
+// history, one JSON encoded importRecord per line.
+const importFile = "imports"
+
+type importRecord struct {
+	Query    string `json:"query"`
+	Filename string `json:"filename"`
+}
+
+// runImport records files that were downloaded before spotiseek took over as
+// downloads of the playlist tracks they match, so they are not searched for again.
+// Files are taken from SLSKD_DOWNLOAD_DIR or from the transfers slskd completed.
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := flags.String("from", "dir", "where to look for downloads: dir or slskd")
+	dryRun := flags.Bool("dry-run", false, "only print what would be imported")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+
+	files, err := importCandidates(*from)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	downloads := &Library{root: os.Getenv("SLSKD_DOWNLOAD_DIR"), files: files}
+
+	playlist := os.Getenv("SOURCE")
+	if playlist == "" {
+		playlist = os.Getenv("SPOTIFY_PLAYLIST_ID")
+	}
+	source, sourceId := ApiClients.DetectSource(playlist)
+	tracks := source.GetTracksSince(context.Background(), sourceId, time.Time{})
+
+	history := LoadHistory("history.json")
+	var records []importRecord
+	for _, track := range tracks {
+		if entry, ok := history.Get(track.Query()); ok && entry.State == StateDownloaded {
+			continue
+		}
+		path, ok := downloads.Find(track)
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("'%s' is %s\n", track.Query(), path)
+		records = append(records, importRecord{track.Query(), path})
+	}
+
+	fmt.Printf("Found %d of %d playlist tracks among %d files\n", len(records), len(tracks), len(files))
+	if *dryRun || len(records) == 0 {
+		return 0
+	}
+
+	file, err := os.OpenFile(importFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			panic(err)
+		}
+		file.Write(append(line, '\n'))
+	}
+
+	fmt.Println("They will be recorded as downloaded on the next playlist check")
+	return 0
+}
+
+// applyImports records the downloads handed over by runImport.
+func applyImports() {
+	for _, line := range takeLines(importFile) {
+		var record importRecord
+		if json.Unmarshal([]byte(line), &record) != nil {
+			continue
+		}
+		if entry, ok := history.Get(record.Query); ok && entry.State == StateDownloaded {
+			continue
+		}
+		fmt.Printf("Imported %s for '%s'\n", record.Filename, record.Query)
+		history.MarkDownloaded(record.Query, record.Filename, "import")
+	}
+}
+
+// importCandidates lists the audio files under SLSKD_DOWNLOAD_DIR or, with from set
+// to slskd, the local files of transfers slskd completed successfully.
+func importCandidates(from string) ([]libraryFile, error) {
+	switch from {
+	case "dir":
+		scan := NewLibrary(os.Getenv("SLSKD_DOWNLOAD_DIR"))
+		// trashed files were removed from the playlist, snapshots hold no audio
+		scan.skip = map[string]bool{".trash": true, "snapshots": true}
+		scan.Scan()
+		return scan.files, nil
+	case "slskd":
+		transfers, err := ApiClients.NewSoulseek(os.Getenv("SLSKD_URL")).GetAllDownloads(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		var files []libraryFile
+		for _, user := range transfers {
+			for _, directory := range user.Directories {
+				for _, file := range directory.Files {
+					path := localDownloadPath(file.Filename)
+					if strings.Contains(file.State, "Succeeded") && fileExists(path) {
+						files = append(files, indexLibraryFile(path))
+					}
+				}
+			}
+		}
+		return files, nil
+	}
+
+	return nil, fmt.Errorf("unknown download location '%s', expected dir or slskd", from)
+}
@@ -0,0 +1,184 @@
+package ApiClients
+
+import (
+	"context"
+	"encoding/json"
+	spotifyVendored "github.com/zmb3/spotify/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArtistReleasesService is a playlist of the releases of a Spotify artist, each of
+// their tracks counts as added on the release date. The playlist id is the artist id,
+// optionally followed by ":singles" or ":albums" to watch only those.
+//
+// Release dates only have day precision and releases show up in the API hours after
+// midnight depending on the market, so new releases are told apart by the albums seen
+// before, which are kept in the state file.
+type ArtistReleasesService struct {
+	spotify   *SpotifyService
+	statePath string
+	mutex     sync.Mutex
+}
+
+func NewArtistReleases(spotify *SpotifyService, statePath string) *ArtistReleasesService {
+	return &ArtistReleasesService{
+		spotify:   spotify,
+		statePath: statePath,
+	}
+}
+
+func parseArtistSpec(playlistId string) (spotifyVendored.ID, spotifyVendored.AlbumType) {
+	id, only, _ := strings.Cut(playlistId, ":")
+	switch only {
+	case "singles":
+		return spotifyVendored.ID(id), spotifyVendored.AlbumTypeSingle
+	case "albums":
+		return spotifyVendored.ID(id), spotifyVendored.AlbumTypeAlbum
+	}
+
+	return spotifyVendored.ID(id), spotifyVendored.AlbumTypeAlbum | spotifyVendored.AlbumTypeSingle
+}
+
+func (ar *ArtistReleasesService) GetPlaylist(ctx context.Context, playlistId string) Playlist {
+	id, _ := parseArtistSpec(playlistId)
+	artist, err := ar.spotify.client.GetArtist(ctx, id)
+	if err != nil {
+		panic(err)
+	}
+
+	return Playlist{ID: playlistId, Name: "Releases by " + artist.Name}
+}
+
+// GetTracksSince lists the tracks of every release when after is zero. Otherwise it
+// returns the tracks of releases that were not seen before and remembers them. The
+// first time an artist is watched, releases from before after count as seen.
+func (ar *ArtistReleasesService) GetTracksSince(ctx context.Context, playlistId string, after time.Time) []Track {
+	albums, err := ar.albums(ctx, playlistId)
+	if err != nil {
+		panic(err)
+	}
+
+	if after.IsZero() {
+		var playlistContents []Track
+		for _, album := range albums {
+			tracks, err := ar.albumTracks(ctx, album)
+			if err != nil {
+				panic(err)
+			}
+			playlistContents = append(playlistContents, tracks...)
+		}
+		return playlistContents
+	}
+
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+
+	state := ar.loadState()
+	seen := make(map[string]bool)
+	for _, id := range state[playlistId] {
+		seen[id] = true
+	}
+	_, watched := state[playlistId]
+
+	var playlistContents []Track
+	for _, album := range albums {
+		if seen[album.ID.String()] || (!watched && !album.ReleaseDateTime().After(after)) {
+			continue
+		}
+		tracks, err := ar.albumTracks(ctx, album)
+		if err != nil {
+			panic(err)
+		}
+		playlistContents = append(playlistContents, tracks...)
+	}
+
+	// only remembered once every new release was read, so none is lost to an error
+	state[playlistId] = []string{}
+	for _, album := range albums {
+		state[playlistId] = append(state[playlistId], album.ID.String())
+	}
+	ar.saveState(state)
+
+	return playlistContents
+}
+
+func (ar *ArtistReleasesService) albums(ctx context.Context, playlistId string) ([]spotifyVendored.SimpleAlbum, error) {
+	id, albumTypes := parseArtistSpec(playlistId)
+
+	var albums []spotifyVendored.SimpleAlbum
+	for offset := 0; ; offset += 50 {
+		page, err := ar.spotify.client.GetArtistAlbums(ctx, id, []spotifyVendored.AlbumType{albumTypes},
+			spotifyVendored.Limit(50), spotifyVendored.Offset(offset))
+		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, page.Albums...)
+		if offset+50 >= int(page.Total) {
+			return albums, nil
+		}
+	}
+}
+
+func (ar *ArtistReleasesService) albumTracks(ctx context.Context, album spotifyVendored.SimpleAlbum) ([]Track, error) {
+	released := album.ReleaseDateTime()
+
+	var tracks []Track
+	for offset := 0; ; offset += 50 {
+		page, err := ar.spotify.client.GetAlbumTracks(ctx, album.ID, spotifyVendored.Limit(50), spotifyVendored.Offset(offset))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, track := range page.Tracks {
+			var artistsFull []string
+			for _, artist := range track.Artists {
+				artistsFull = append(artistsFull, artist.Name)
+			}
+
+			entry := Track{
+				ID:          track.ID.String(),
+				Artists:     artistsFull,
+				Title:       track.Name,
+				Duration:    track.TimeDuration(),
+				AddedAt:     released,
+				Album:       album.Name,
+				Year:        released.Year(),
+				Compilation: album.AlbumType == "compilation",
+			}
+			if len(album.Images) > 0 {
+				entry.ArtworkURL = album.Images[0].URL
+			}
+			tracks = append(tracks, entry)
+		}
+		if offset+50 >= int(page.Total) {
+			return tracks, nil
+		}
+	}
+}
+
+// loadState reads the ids of the albums seen so far per watched artist.
+func (ar *ArtistReleasesService) loadState() map[string][]string {
+	state := make(map[string][]string)
+	contents, err := os.ReadFile(ar.statePath)
+	if err != nil {
+		return state
+	}
+	json.Unmarshal(contents, &state)
+
+	return state
+}
+
+func (ar *ArtistReleasesService) saveState(state map[string][]string) {
+	contents, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	os.WriteFile(ar.statePath, contents, 0666)
+}
+
+func (ar *ArtistReleasesService) GetTempo(ctx context.Context, trackId string) (float64, error) {
+	return ar.spotify.GetTempo(ctx, trackId)
+}
@@ -0,0 +1,171 @@
+package ApiClients
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cachedResponse struct {
+	ETag    string    `json:"etag"`
+	Expires time.Time `json:"expires"`
+	Raw     []byte    `json:"raw"`
+}
+
+// CachingTransport answers repeated GET requests from a cache: fresh entries per
+// Cache-Control max-age are served without a request, stale ones are revalidated
+// with If-None-Match. Entries are also written to dir when it is set.
+type CachingTransport struct {
+	base    http.RoundTripper
+	dir     string
+	mutex   sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func NewCachingTransport(base http.RoundTripper, dir string) *CachingTransport {
+	if dir != "" {
+		os.MkdirAll(dir, 0777)
+	}
+
+	return &CachingTransport{
+		base:    base,
+		dir:     dir,
+		entries: make(map[string]*cachedResponse),
+	}
+}
+
+func (ct *CachingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != "GET" {
+		return ct.base.RoundTrip(request)
+	}
+
+	key := request.URL.String()
+	cached := ct.load(key)
+	if cached != nil && time.Now().Before(cached.Expires) {
+		return cached.response(request)
+	}
+
+	if cached != nil && cached.ETag != "" {
+		request = request.Clone(request.Context())
+		request.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	response, err := ct.base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		response.Body.Close()
+		refreshed := *cached
+		refreshed.Expires = expiresAt(response.Header)
+		ct.store(key, &refreshed)
+		return refreshed.response(request)
+	}
+
+	cacheControl := response.Header.Get("Cache-Control")
+	if response.StatusCode != http.StatusOK || strings.Contains(cacheControl, "no-store") {
+		return response, nil
+	}
+	etag := response.Header.Get("ETag")
+	expires := expiresAt(response.Header)
+	if etag == "" && !time.Now().Before(expires) {
+		return response, nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	stored := *response
+	stored.Body = io.NopCloser(bytes.NewReader(body))
+	if stored.Write(&raw) == nil {
+		ct.store(key, &cachedResponse{ETag: etag, Expires: expires, Raw: raw.Bytes()})
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	return response, nil
+}
+
+func (cr *cachedResponse) response(request *http.Request) (*http.Response, error) {
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(cr.Raw)), request)
+}
+
+func expiresAt(header http.Header) time.Time {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name == "no-cache" {
+			return time.Time{}
+		}
+		if name == "max-age" {
+			seconds, err := strconv.Atoi(value)
+			if err == nil {
+				return time.Now().Add(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// load returns a copy of the cached response, stored entries are never changed
+// since concurrent requests read them.
+func (ct *CachingTransport) load(key string) *cachedResponse {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	if cached, ok := ct.entries[key]; ok {
+		copied := *cached
+		return &copied
+	}
+	if ct.dir == "" {
+		return nil
+	}
+
+	contents, err := os.ReadFile(ct.path(key))
+	if err != nil {
+		return nil
+	}
+	var cached cachedResponse
+	if json.Unmarshal(contents, &cached) != nil {
+		return nil
+	}
+	ct.entries[key] = &cached
+	copied := cached
+
+	return &copied
+}
+
+func (ct *CachingTransport) store(key string, cached *cachedResponse) {
+	ct.mutex.Lock()
+	defer ct.mutex.Unlock()
+
+	ct.entries[key] = cached
+	if ct.dir == "" {
+		return
+	}
+
+	contents, err := json.Marshal(cached)
+	if err == nil {
+		os.WriteFile(ct.path(key), contents, 0666)
+	}
+}
+
+func (ct *CachingTransport) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+
+	return filepath.Join(ct.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,107 @@
+package ApiClients
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type DeezerService struct {
+	httpHost   string
+	httpClient http.Client
+}
+
+type deezerPlaylist struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+type deezerTrackPage struct {
+	Data []struct {
+		ID       int    `json:"id"`
+		Title    string `json:"title"`
+		Duration int    `json:"duration"`
+		TimeAdd  int64  `json:"time_add"`
+		Artist   struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+		Album struct {
+			Title   string `json:"title"`
+			CoverXl string `json:"cover_xl"`
+		} `json:"album"`
+	} `json:"data"`
+	Next string `json:"next"`
+}
+
+func NewDeezer() *DeezerService {
+	return &DeezerService{
+		httpHost:   "https://api.deezer.com",
+		httpClient: http.Client{},
+	}
+}
+
+func (ds *DeezerService) GetPlaylist(ctx context.Context, playlistId string) Playlist {
+	var playlist deezerPlaylist
+	ds.get(ctx, ds.httpHost+"/playlist/"+url.PathEscape(playlistId), &playlist)
+
+	return Playlist{ID: playlistId, Name: playlist.Title}
+}
+
+func (ds *DeezerService) GetTracksSince(ctx context.Context, playlistId string, after time.Time) []Track {
+	var playlistContents []Track
+
+	next := ds.httpHost + "/playlist/" + url.PathEscape(playlistId) + "/tracks?limit=100"
+	for next != "" {
+		var page deezerTrackPage
+		ds.get(ctx, next, &page)
+
+		for _, track := range page.Data {
+			trackTime := time.Unix(track.TimeAdd, 0)
+			if !trackTime.After(after) {
+				continue
+			}
+
+			entry := Track{
+				ID:         strconv.Itoa(track.ID),
+				Artists:    []string{track.Artist.Name},
+				Title:      track.Title,
+				Duration:   time.Duration(track.Duration) * time.Second,
+				AddedAt:    trackTime,
+				Album:      track.Album.Title,
+				ArtworkURL: track.Album.CoverXl,
+			}
+			playlistContents = append(playlistContents, entry)
+		}
+		next = page.Next
+	}
+
+	return playlistContents
+}
+
+func (ds *DeezerService) get(ctx context.Context, endpoint string, target any) {
+	request, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	response, err := ds.httpClient.Do(request)
+	if err != nil {
+		panic(err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			panic(err)
+		}
+	}(response.Body)
+
+	body, _ := io.ReadAll(response.Body)
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		panic(err)
+	}
+}
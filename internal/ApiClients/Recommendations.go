@@ -0,0 +1,117 @@
+package ApiClients
+
+import (
+	"context"
+	"fmt"
+	spotifyVendored "github.com/zmb3/spotify/v2"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RecommendationsService is a playlist of Spotify recommendations for a seed artist
+// or track that gains up to perWeek new tracks every week. The recommendations change
+// with every request, so the source is Changing. Spotify only serves GET /recommendations
+// to apps created before November 27, 2024, newer apps get 404 for it.
+type RecommendationsService struct {
+	spotify *SpotifyService
+	perWeek int
+}
+
+func NewRecommendations(spotify *SpotifyService, perWeek int) *RecommendationsService {
+	return &RecommendationsService{
+		spotify: spotify,
+		perWeek: perWeek,
+	}
+}
+
+// parseSeed reads "artist:<id>", "track:<id>" or an open.spotify.com artist or track URL.
+func parseSeed(playlistId string) (string, spotifyVendored.ID) {
+	parsed, err := url.Parse(playlistId)
+	if err == nil && parsed.Host != "" {
+		playlistId = strings.Replace(strings.Trim(parsed.Path, "/"), "/", ":", 1)
+	}
+	kind, id, _ := strings.Cut(playlistId, ":")
+
+	return kind, spotifyVendored.ID(id)
+}
+
+func (rs *RecommendationsService) GetPlaylist(ctx context.Context, playlistId string) Playlist {
+	name, err := rs.seedName(ctx, playlistId)
+	if err != nil {
+		panic(err)
+	}
+
+	return Playlist{ID: playlistId, Name: "Recommendations for " + name}
+}
+
+func (rs *RecommendationsService) seedName(ctx context.Context, playlistId string) (string, error) {
+	kind, id := parseSeed(playlistId)
+	switch kind {
+	case "artist":
+		artist, err := rs.spotify.client.GetArtist(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		return artist.Name, nil
+	case "track":
+		track, err := rs.spotify.client.GetTrack(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		return track.Name, nil
+	}
+
+	return "", fmt.Errorf("recommendations need an artist or track seed, got '%s'", playlistId)
+}
+
+// Changing reports that the recommendations are different on every request.
+func (rs *RecommendationsService) Changing(playlistId string) bool {
+	return true
+}
+
+// GetTracksSince asks for new recommendations once per week, the first check after
+// the week started returns them as added at its start.
+func (rs *RecommendationsService) GetTracksSince(ctx context.Context, playlistId string, after time.Time) []Track {
+	now := time.Now()
+	week := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, now.Location())
+	if !week.After(after) {
+		return nil
+	}
+
+	kind, id := parseSeed(playlistId)
+	var seeds spotifyVendored.Seeds
+	if kind == "track" {
+		seeds.Tracks = []spotifyVendored.ID{id}
+	} else {
+		seeds.Artists = []spotifyVendored.ID{id}
+	}
+	recommendations, err := rs.spotify.client.GetRecommendations(ctx, seeds, nil, spotifyVendored.Limit(rs.perWeek))
+	if err != nil {
+		panic(fmt.Errorf("spotify recommendations, which apps created after November 27, 2024 cannot use: %w", err))
+	}
+
+	var playlistContents []Track
+	for _, track := range recommendations.Tracks {
+		var artistsFull []string
+		for _, artist := range track.Artists {
+			artistsFull = append(artistsFull, artist.Name)
+		}
+
+		entry := Track{
+			ID:       track.ID.String(),
+			Artists:  artistsFull,
+			Title:    track.Name,
+			Duration: track.TimeDuration(),
+			AddedAt:  week,
+			ISRC:     track.ExternalIDs.ISRC,
+		}
+		playlistContents = append(playlistContents, entry)
+	}
+
+	return playlistContents
+}
+
+func (rs *RecommendationsService) GetTempo(ctx context.Context, trackId string) (float64, error) {
+	return rs.spotify.GetTempo(ctx, trackId)
+}
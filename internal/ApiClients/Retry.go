@@ -0,0 +1,89 @@
+package ApiClients
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryTransport repeats requests that failed with a network error or a 429 or 5xx
+// answer, waiting twice as long before every attempt. Requests that still fail count
+// towards a circuit breaker which trips after threshold failures in a row and resets
+// on the next success.
+type RetryTransport struct {
+	base      http.RoundTripper
+	attempts  int
+	backoff   time.Duration
+	threshold int
+	mutex     sync.Mutex
+	failures  int
+}
+
+func NewRetryTransport(base http.RoundTripper, attempts int, backoff time.Duration, threshold int) *RetryTransport {
+	return &RetryTransport{
+		base:      base,
+		attempts:  attempts,
+		backoff:   backoff,
+		threshold: threshold,
+	}
+}
+
+func (rt *RetryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := rt.base.RoundTrip(request)
+	for attempt := 1; attempt < rt.attempts && isTransient(request, response, err); attempt++ {
+		// a body that cannot be read again cannot be sent again
+		if request.Body != nil && request.GetBody == nil {
+			break
+		}
+		select {
+		case <-request.Context().Done():
+		case <-time.After(rt.backoff << (attempt - 1)):
+		}
+		if request.Context().Err() != nil {
+			break
+		}
+
+		retry := request.Clone(request.Context())
+		if request.GetBody != nil {
+			body, bodyErr := request.GetBody()
+			if bodyErr != nil {
+				break
+			}
+			retry.Body = body
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+		response, err = rt.base.RoundTrip(retry)
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	if isTransient(request, response, err) {
+		rt.failures++
+	} else if request.Context().Err() == nil {
+		rt.failures = 0
+	}
+
+	return response, err
+}
+
+// Tripped reports whether the last threshold requests all failed.
+func (rt *RetryTransport) Tripped() bool {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	return rt.threshold > 0 && rt.failures >= rt.threshold
+}
+
+func isTransient(request *http.Request, response *http.Response, err error) bool {
+	if err != nil {
+		return request.Context().Err() == nil
+	}
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
@@ -2,24 +2,63 @@ package ApiClients
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	json2 "encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
+// The kinds of RequestError a failed slskd request returns. Callers can tell with
+// errors.As or errors.Is whether to retry, requeue or alert.
+var (
+	ErrUnauthorized  = errors.New("slskd rejected the credentials")
+	ErrNotFound      = errors.New("slskd does not know the resource")
+	ErrRateLimited   = errors.New("slskd is rate limiting requests")
+	ErrServerOffline = errors.New("slskd is unreachable")
+)
+
+// RequestError is a slskd request that failed, Kind is one of the errors above or
+// nil for other failures.
+type RequestError struct {
+	Kind     error
+	Endpoint string
+	Err      error
+}
+
+func (e *RequestError) Error() string {
+	if e.Kind == nil {
+		return fmt.Sprintf("%s: %s", e.Endpoint, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Kind, e.Endpoint, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Kind
+}
+
 type SoulseekService struct {
 	httpHost   string
 	httpClient http.Client
+	retry      *RetryTransport
 }
 
 type Soulseek interface {
-	Search(query string) SearchResult
-	GetSearchResult(searchId string) SearchResult
-	Transfer(username string, downloadId string, fileSize int) string
+	Search(ctx context.Context, query string) (SearchResult, error)
+	GetSearchResult(ctx context.Context, searchId string) (SearchResult, error)
+	Transfer(ctx context.Context, username string, downloadId string, fileSize int) (string, error)
+	GetDownloads(ctx context.Context, username string) (UserTransfers, error)
+	GetAllDownloads(ctx context.Context) ([]UserTransfers, error)
+	GetServerState(ctx context.Context) (ServerState, error)
+	BrowseDirectory(ctx context.Context, username string, directory string) (Directory, error)
+	CancelDownload(ctx context.Context, username string, id string) error
+	Connect(ctx context.Context) error
+	Failing() bool
 }
 
 type SearchResult struct {
@@ -58,79 +97,118 @@ type File struct {
 	IsLocked  bool   `json:"isLocked"`
 }
 
+// Directory is a folder shared by a user, its files are named without the directory.
+type Directory struct {
+	Name      string `json:"name"`
+	FileCount int    `json:"fileCount"`
+	Files     []File `json:"files"`
+}
+
+type ServerState struct {
+	Address         string `json:"address"`
+	State           string `json:"state"`
+	IsConnected     bool   `json:"isConnected"`
+	IsLoggedIn      bool   `json:"isLoggedIn"`
+	IsTransitioning bool   `json:"isTransitioning"`
+}
+
+type UserTransfers struct {
+	Username    string              `json:"username"`
+	Directories []TransferDirectory `json:"directories"`
+}
+
+type TransferDirectory struct {
+	Directory string         `json:"directory"`
+	FileCount int            `json:"fileCount"`
+	Files     []TransferFile `json:"files"`
+}
+
+type TransferFile struct {
+	ID               string  `json:"id"`
+	Username         string  `json:"username"`
+	Filename         string  `json:"filename"`
+	Size             int     `json:"size"`
+	State            string  `json:"state"`
+	BytesTransferred int     `json:"bytesTransferred"`
+	AverageSpeed     float64 `json:"averageSpeed"`
+	PercentComplete  float64 `json:"percentComplete"`
+}
+
+// Find returns the transfer of the given remote file, if slskd still knows about it.
+func (ut UserTransfers) Find(filename string) (TransferFile, bool) {
+	for _, directory := range ut.Directories {
+		for _, file := range directory.Files {
+			if file.Filename == filename {
+				return file, true
+			}
+		}
+	}
+
+	return TransferFile{}, false
+}
+
+// CountActive returns how many transfers of this user have not completed yet.
+func (ut UserTransfers) CountActive() int {
+	active := 0
+	for _, directory := range ut.Directories {
+		for _, file := range directory.Files {
+			if !strings.Contains(file.State, "Completed") {
+				active++
+			}
+		}
+	}
+
+	return active
+}
+
+// NewSoulseek creates a client that retries failed requests three times and reports
+// Failing once five requests in a row failed nonetheless.
 func NewSoulseek(host string) *SoulseekService {
+	retry := NewRetryTransport(http.DefaultTransport, 3, time.Second, 5)
 	ss := &SoulseekService{
 		httpHost:   host,
-		httpClient: http.Client{},
+		httpClient: http.Client{Transport: retry},
+		retry:      retry,
 	}
 
 	return ss
 }
 
-func (ss *SoulseekService) Search(query string) SearchResult {
+func (ss *SoulseekService) Search(ctx context.Context, query string) (SearchResult, error) {
 	apiEndpoint := "/api/v0/searches"
 
-	var jsonData = []byte(`{
-		"searchText": "` + query + `"
-	}`)
-	request, err := http.NewRequest("POST", ss.httpHost+apiEndpoint, bytes.NewBuffer(jsonData))
-	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
-
-	response, err := ss.httpClient.Do(request)
+	// overrides and aliases can hold quotes and backslashes, so the body is encoded
+	jsonRaw, err := json.Marshal(map[string]string{"searchText": query})
 	if err != nil {
-		panic(err)
+		return SearchResult{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(response.Body)
-
-	body, _ := io.ReadAll(response.Body)
-	var searchResult = SearchResult{}
-	err = json2.Unmarshal(body, &searchResult)
+	request, err := http.NewRequestWithContext(ctx, "POST", ss.httpHost+apiEndpoint, bytes.NewBuffer(jsonRaw))
 	if err != nil {
-		panic(err)
+		return SearchResult{}, err
 	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
 
-	return searchResult
+	var searchResult = SearchResult{}
+	err = ss.decode(request, &searchResult)
 
+	return searchResult, err
 }
 
-func (ss *SoulseekService) GetSearchResult(query string) SearchResult {
+func (ss *SoulseekService) GetSearchResult(ctx context.Context, query string) (SearchResult, error) {
 	apiEndpoint := "/api/v0/searches/"
 
-	var jsonData = []byte(`{
-		"searchText": "` + query + `"
-	}`)
-	request, err := http.NewRequest("GET", ss.httpHost+apiEndpoint+query+"?includeResponses=true", bytes.NewBuffer(jsonData))
-	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
-
-	response, err := ss.httpClient.Do(request)
+	request, err := http.NewRequestWithContext(ctx, "GET", ss.httpHost+apiEndpoint+url.PathEscape(query)+"?includeResponses=true", nil)
 	if err != nil {
-		panic(err)
+		return SearchResult{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(response.Body)
 
-	body, _ := io.ReadAll(response.Body)
 	var searchResult = SearchResult{}
+	err = ss.decode(request, &searchResult)
 
-	err = json2.Unmarshal(body, &searchResult)
-	if err != nil {
-		fmt.Println(body)
-		panic(err)
-	}
-
-	return searchResult
+	return searchResult, err
 }
 
-func (ss SoulseekService) Transfer(username string, filename string, size int) string {
+func (ss SoulseekService) Transfer(ctx context.Context, username string, filename string, size int) (string, error) {
 	apiEndpoint := "/api/v0/transfers/downloads/"
 
 	apiEndpoint += url.PathEscape(username)
@@ -145,38 +223,221 @@ func (ss SoulseekService) Transfer(username string, filename string, size int) s
 
 	jsonRaw, err := json.Marshal(jsonEncapsulated)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	fmt.Printf(string(jsonRaw))
-	request, err := http.NewRequest("POST", ss.httpHost+apiEndpoint, bytes.NewBuffer(jsonRaw))
+	request, err := http.NewRequestWithContext(ctx, "POST", ss.httpHost+apiEndpoint, bytes.NewBuffer(jsonRaw))
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
 
-	response, err := ss.httpClient.Do(request)
-	fmt.Printf("HTTP %s", response.Status)
+	response, err := ss.do(request)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(response.Body)
+	fmt.Printf("HTTP %s", response.Status)
+	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	fmt.Println(body)
 
+	return username + filename, nil
+}
+
+func (ss *SoulseekService) GetDownloads(ctx context.Context, username string) (UserTransfers, error) {
+	apiEndpoint := "/api/v0/transfers/downloads/"
+
+	request, err := http.NewRequestWithContext(ctx, "GET", ss.httpHost+apiEndpoint+url.PathEscape(username), nil)
+	if err != nil {
+		return UserTransfers{}, err
+	}
+
+	var transfers = UserTransfers{}
+	err = ss.decode(request, &transfers)
+	if errors.Is(err, ErrNotFound) {
+		// slskd has no transfers of this user (anymore)
+		return transfers, nil
+	}
+
+	return transfers, err
+}
+
+// CancelDownload stops a transfer and removes it from the slskd transfer list.
+func (ss *SoulseekService) CancelDownload(ctx context.Context, username string, id string) error {
+	apiEndpoint := "/api/v0/transfers/downloads/" + url.PathEscape(username) + "/" + url.PathEscape(id)
+
+	request, err := http.NewRequestWithContext(ctx, "DELETE", ss.httpHost+apiEndpoint+"?remove=true", nil)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	response, err := ss.do(request)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+func (ss *SoulseekService) GetAllDownloads(ctx context.Context) ([]UserTransfers, error) {
+	apiEndpoint := "/api/v0/transfers/downloads"
+
+	request, err := http.NewRequestWithContext(ctx, "GET", ss.httpHost+apiEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []UserTransfers
+	err = ss.decode(request, &transfers)
+
+	return transfers, err
+}
+
+func (ss *SoulseekService) GetServerState(ctx context.Context) (ServerState, error) {
+	apiEndpoint := "/api/v0/server"
+
+	request, err := http.NewRequestWithContext(ctx, "GET", ss.httpHost+apiEndpoint, nil)
+	if err != nil {
+		return ServerState{}, err
+	}
+
+	var state = ServerState{}
+	err = ss.decode(request, &state)
+
+	return state, err
+}
+
+// Connect asks slskd to connect to the Soulseek server, which also succeeds when it
+// already is connected.
+func (ss *SoulseekService) Connect(ctx context.Context) error {
+	apiEndpoint := "/api/v0/server"
+
+	request, err := http.NewRequestWithContext(ctx, "PUT", ss.httpHost+apiEndpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := ss.do(request)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// decode sends request and unmarshals the JSON answer into v.
+func (ss *SoulseekService) decode(request *http.Request, v any) error {
+	response, err := ss.do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	err = json2.Unmarshal(body, v)
+	if err != nil {
+		return &RequestError{Endpoint: endpoint(request), Err: err}
+	}
+
+	return nil
+}
+
+// do sends request and returns a RequestError when slskd cannot be reached or
+// answers with an error status.
+func (ss *SoulseekService) do(request *http.Request) (*http.Response, error) {
+	response, err := ss.httpClient.Do(request)
+	if err != nil && request.Context().Err() == nil {
+		return nil, &RequestError{Kind: ErrServerOffline, Endpoint: endpoint(request), Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(response); err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func endpoint(request *http.Request) string {
+	return request.Method + " " + request.URL.Path
+}
+
+func statusError(response *http.Response) error {
+	var kind error
+	switch {
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		kind = ErrUnauthorized
+	case response.StatusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case response.StatusCode == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case response.StatusCode >= 500:
+		kind = ErrServerOffline
+	case response.StatusCode < 400:
+		return nil
+	}
+
+	return &RequestError{Kind: kind, Endpoint: endpoint(response.Request), Err: fmt.Errorf("answered HTTP %s", response.Status)}
+}
+
+// Failing reports whether slskd keeps failing requests despite the retries.
+func (ss *SoulseekService) Failing() bool {
+	return ss.retry.Tripped()
+}
+
+// BrowseDirectory asks a user for the contents of one of their shared folders.
+func (ss *SoulseekService) BrowseDirectory(ctx context.Context, username string, directory string) (Directory, error) {
+	apiEndpoint := "/api/v0/users/" + url.PathEscape(username) + "/directory"
+
+	jsonRaw, err := json.Marshal(map[string]string{"directory": directory})
+	if err != nil {
+		return Directory{}, err
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", ss.httpHost+apiEndpoint, bytes.NewBuffer(jsonRaw))
+	if err != nil {
+		return Directory{}, err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := ss.do(request)
+	if err != nil {
+		return Directory{}, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Directory{}, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return Directory{}, fmt.Errorf("browsing %s of %s: HTTP %s", directory, username, response.Status)
+	}
+
+	// depending on the version slskd answers with the directory or a list holding it
+	var directories []Directory
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		err = json2.Unmarshal(body, &directories)
+	} else {
+		directories = make([]Directory, 1)
+		err = json2.Unmarshal(body, &directories[0])
+	}
+	if err != nil {
+		return Directory{}, err
+	}
+	if len(directories) == 0 {
+		return Directory{Name: directory}, nil
 	}
 
-	return username + filename
+	return directories[0], nil
 }
@@ -0,0 +1,100 @@
+package ApiClients
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Playlist struct {
+	ID   string
+	Name string
+}
+
+// Track is a playlist entry. Album, Year, ISRC and ArtworkURL are left empty by
+// sources that do not know them.
+type Track struct {
+	ID         string
+	Artists    []string
+	Title      string
+	Duration   time.Duration
+	AddedAt    time.Time
+	Album      string
+	Year       int
+	ISRC       string
+	ArtworkURL string
+	// Compilation is set for tracks from various artists albums.
+	Compilation bool
+}
+
+// Query is the Soulseek search text for the track.
+func (t Track) Query() string {
+	return strings.Join(append(append([]string{}, t.Artists...), t.Title), " ")
+}
+
+// PlaylistSource is a streaming service (or anything playlist-like) tracks are taken from.
+type PlaylistSource interface {
+	GetPlaylist(ctx context.Context, playlistId string) Playlist
+	GetTracksSince(ctx context.Context, playlistId string, after time.Time) []Track
+}
+
+// ChangingSource is implemented by sources whose tracks are not a fixed list but are
+// replaced with every request or period, such as recommendations or a weekly chart.
+// Their listing cannot be mirrored or exported as a playlist.
+type ChangingSource interface {
+	Changing(playlistId string) bool
+}
+
+// IsChanging reports whether the source replaces its tracks over time.
+func IsChanging(source PlaylistSource, playlistId string) bool {
+	changing, ok := source.(ChangingSource)
+
+	return ok && changing.Changing(playlistId)
+}
+
+// TempoSource is implemented by sources that know the tempo of their tracks, which
+// for Spotify needs an app created before November 27, 2024.
+type TempoSource interface {
+	GetTempo(ctx context.Context, trackId string) (float64, error)
+}
+
+// DetectSource picks the provider from a playlist URL or a "lastfm:", "recommendations:"
+// or "artist:" spec and returns it together with the provider specific playlist id.
+// Bare ids are treated as Spotify playlists.
+func DetectSource(playlist string) (PlaylistSource, string) {
+	if strings.HasPrefix(playlist, "lastfm:") {
+		return NewLastFm(os.Getenv("LASTFM_API_KEY")), strings.TrimPrefix(playlist, "lastfm:")
+	}
+	if strings.HasPrefix(playlist, "recommendations:") {
+		perWeek, err := strconv.Atoi(os.Getenv("RECOMMENDATIONS_PER_WEEK"))
+		if err != nil {
+			perWeek = 10
+		}
+		spotify := NewSpotify(os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"), os.Getenv("SPOTIFY_CACHE_DIR"))
+		return NewRecommendations(spotify, perWeek), strings.TrimPrefix(playlist, "recommendations:")
+	}
+	if strings.HasPrefix(playlist, "artist:") {
+		spotify := NewSpotify(os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"), os.Getenv("SPOTIFY_CACHE_DIR"))
+		return NewArtistReleases(spotify, "releases.json"), strings.TrimPrefix(playlist, "artist:")
+	}
+
+	parsed, err := url.Parse(playlist)
+	if err == nil && parsed.Host != "" {
+		id := parsed.Path[strings.LastIndex(parsed.Path, "/")+1:]
+		switch {
+		case strings.HasSuffix(parsed.Host, "deezer.com"):
+			return NewDeezer(), id
+		case strings.HasSuffix(parsed.Host, "tidal.com"):
+			return NewTidal(os.Getenv("TIDAL_ID"), os.Getenv("TIDAL_SECRET")), id
+		case strings.HasSuffix(parsed.Host, "spotify.com") && strings.Contains(parsed.Path, "/artist/"):
+			return DetectSource("artist:" + id)
+		case strings.HasSuffix(parsed.Host, "spotify.com"):
+			playlist = id
+		}
+	}
+
+	return NewSpotify(os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"), os.Getenv("SPOTIFY_CACHE_DIR")), playlist
+}
@@ -3,15 +3,26 @@ package ApiClients
 import (
 	"context"
 	"fmt"
-	spotifyVendored "github.com/zmb3/spotify"
+	spotifyVendored "github.com/zmb3/spotify/v2"
+	spotifyauth "github.com/zmb3/spotify/v2/auth"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/clientcredentials"
-	"log"
-	"strings"
+	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// spotifyPageSize is the largest page the playlist tracks endpoint returns and
+// spotifyPageFetchers bounds how many of those pages are requested at once.
+const spotifyPageSize = 100
+const spotifyPageFetchers = 4
+
+// spotifyTrackFields limits playlist pages to what Track is built from.
+const spotifyTrackFields = "total,items(added_at,track(id,name,duration_ms,artists(name),album(name,release_date,images,album_type),external_ids))"
+
 type SpotifyService struct {
-	client spotifyVendored.Client
+	client *spotifyVendored.Client
 }
 
 type Spotify interface {
@@ -21,18 +32,35 @@ type Spotify interface {
 	Search() string
 }
 
-func NewSpotify(clientId string, clientSecret string) *SpotifyService {
-	config := &clientcredentials.Config{
+func spotifyConfig(clientId string, clientSecret string) *clientcredentials.Config {
+	return &clientcredentials.Config{
 		ClientID:     clientId,
 		ClientSecret: clientSecret,
-		TokenURL:     spotifyVendored.TokenURL,
+		TokenURL:     spotifyauth.TokenURL,
 	}
-	token, err := config.Token(context.Background())
+}
+
+// CheckSpotifyCredentials performs a token request without exiting on failure.
+func CheckSpotifyCredentials(clientId string, clientSecret string) error {
+	_, err := spotifyConfig(clientId, clientSecret).Token(context.Background())
+
+	return err
+}
+
+// NewSpotify creates a client whose API responses are cached in memory and, when
+// cacheDir is set, on disk, so polling unchanged playlists costs no quota.
+func NewSpotify(clientId string, clientSecret string, cacheDir string) *SpotifyService {
+	httpClient := &http.Client{Transport: NewCachingTransport(http.DefaultTransport, cacheDir)}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+
+	config := spotifyConfig(clientId, clientSecret)
+	token, err := config.Token(ctx)
 	if err != nil {
-		log.Fatalf("couldn't get token: %v", err)
+		panic(fmt.Errorf("couldn't get token: %w", err))
 	}
 
-	realClient := spotifyVendored.Authenticator{}.NewClient(token)
+	tokenSource := oauth2.ReuseTokenSource(token, config.TokenSource(ctx))
+	realClient := spotifyVendored.New(oauth2.NewClient(ctx, tokenSource))
 	return &SpotifyService{
 		client: realClient,
 	}
@@ -43,14 +71,23 @@ func (spotifyService *SpotifyService) Auth() bool {
 	return true
 }
 
-func (spotifyService *SpotifyService) GetPlaylistTracks(playlistId string, after time.Time) []string {
-	tracks, err := spotifyService.client.GetPlaylistTracks(spotifyVendored.ID(playlistId))
+func (spotifyService *SpotifyService) GetPlaylist(ctx context.Context, playlistId string) Playlist {
+	playlist, err := spotifyService.client.GetPlaylist(ctx, spotifyVendored.ID(playlistId), spotifyVendored.Fields("id,name"))
+	if err != nil {
+		panic(err)
+	}
+
+	return Playlist{ID: playlistId, Name: playlist.Name}
+}
+
+func (spotifyService *SpotifyService) GetTracksSince(ctx context.Context, playlistId string, after time.Time) []Track {
+	playlistTracks, err := spotifyService.getAllPlaylistTracks(ctx, playlistId)
 	if err != nil {
-		log.Fatal(err)
+		panic(err)
 	}
 
-	var playlistContents []string
-	for _, track := range tracks.Tracks {
+	var playlistContents []Track
+	for _, track := range playlistTracks {
 		trackTime, _ := time.Parse(time.RFC3339, track.AddedAt)
 		if !trackTime.After(after) {
 			//fmt.Println(track.Track.Name, trackTime.GoString(), after.GoString(), "Continuing")
@@ -63,14 +100,77 @@ func (spotifyService *SpotifyService) GetPlaylistTracks(playlistId string, after
 			artistsFull = append(artistsFull, artists.Name)
 		}
 
-		entryFull := fmt.Sprintf("%s %s", strings.Join(artistsFull, " "), track.Track.Name)
-		log.Printf("Found playlist entry: '%s'", entryFull)
-		playlistContents = append(playlistContents, entryFull)
+		entry := Track{
+			ID:          track.Track.ID.String(),
+			Artists:     artistsFull,
+			Title:       track.Track.Name,
+			Duration:    track.Track.TimeDuration(),
+			AddedAt:     trackTime,
+			Album:       track.Track.Album.Name,
+			ISRC:        track.Track.ExternalIDs["isrc"],
+			Compilation: track.Track.Album.AlbumType == "compilation",
+		}
+		if len(track.Track.Album.ReleaseDate) >= 4 {
+			entry.Year, _ = strconv.Atoi(track.Track.Album.ReleaseDate[:4])
+		}
+		if len(track.Track.Album.Images) > 0 {
+			// images are ordered widest first
+			entry.ArtworkURL = track.Track.Album.Images[0].URL
+		}
+		playlistContents = append(playlistContents, entry)
 	}
 
 	return playlistContents
 }
 
+// getAllPlaylistTracks reads the first page to learn the size of the playlist and
+// then fetches the remaining pages concurrently, keeping the playlist order.
+func (spotifyService *SpotifyService) getAllPlaylistTracks(ctx context.Context, playlistId string) ([]spotifyVendored.PlaylistTrack, error) {
+	first, err := spotifyService.client.GetPlaylistTracks(ctx, spotifyVendored.ID(playlistId), spotifyVendored.Limit(spotifyPageSize), spotifyVendored.Fields(spotifyTrackFields))
+	if err != nil {
+		return nil, err
+	}
+
+	pageCount := (int(first.Total) + spotifyPageSize - 1) / spotifyPageSize
+	pages := make([][]spotifyVendored.PlaylistTrack, pageCount)
+	if pageCount > 0 {
+		pages[0] = first.Tracks
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, pageCount)
+	fetchers := make(chan struct{}, spotifyPageFetchers)
+	for i := 1; i < pageCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fetchers <- struct{}{}
+			defer func() { <-fetchers }()
+
+			page, err := spotifyService.client.GetPlaylistTracks(ctx, spotifyVendored.ID(playlistId),
+				spotifyVendored.Limit(spotifyPageSize), spotifyVendored.Offset(i*spotifyPageSize), spotifyVendored.Fields(spotifyTrackFields))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			pages[i] = page.Tracks
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tracks []spotifyVendored.PlaylistTrack
+	for _, page := range pages {
+		tracks = append(tracks, page...)
+	}
+
+	return tracks, nil
+}
+
 //func (spotifyService *SpotifyService) Search(query string) string {
 //	return "ad"
 //}
@@ -78,3 +178,16 @@ func (spotifyService *SpotifyService) GetPlaylistTracks(playlistId string, after
 //type Spotify interface {
 //	auth() bool
 //}
+
+// GetTempo returns the tempo in beats per minute Spotify's audio analysis found for a track.
+func (spotifyService *SpotifyService) GetTempo(ctx context.Context, trackId string) (float64, error) {
+	features, err := spotifyService.client.GetAudioFeatures(ctx, spotifyVendored.ID(trackId))
+	if err != nil {
+		return 0, fmt.Errorf("getting the audio features of %s, which apps created after 2024-11-27 cannot: %w", trackId, err)
+	}
+	if len(features) == 0 || features[0] == nil || features[0].Tempo == 0 {
+		return 0, fmt.Errorf("spotify has no tempo for %s", trackId)
+	}
+
+	return float64(features[0].Tempo), nil
+}
@@ -0,0 +1,160 @@
+package ApiClients
+
+import (
+	"context"
+	"encoding/json"
+	"golang.org/x/oauth2/clientcredentials"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type TidalService struct {
+	httpHost    string
+	countryCode string
+	httpClient  *http.Client
+}
+
+type tidalResource struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name     string `json:"name"`
+		Title    string `json:"title"`
+		Duration string `json:"duration"`
+	} `json:"attributes"`
+	Relationships struct {
+		Artists struct {
+			Data []tidalResource `json:"data"`
+		} `json:"artists"`
+	} `json:"relationships"`
+	Meta struct {
+		AddedAt time.Time `json:"addedAt"`
+	} `json:"meta"`
+}
+
+type tidalDocument struct {
+	Data     json.RawMessage `json:"data"`
+	Included []tidalResource `json:"included"`
+	Links    struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+func NewTidal(clientId string, clientSecret string) *TidalService {
+	config := &clientcredentials.Config{
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		TokenURL:     "https://auth.tidal.com/v1/oauth2/token",
+	}
+
+	return &TidalService{
+		httpHost:    "https://openapi.tidal.com/v2",
+		countryCode: "US",
+		httpClient:  config.Client(nil),
+	}
+}
+
+func (ts *TidalService) GetPlaylist(ctx context.Context, playlistId string) Playlist {
+	var document tidalDocument
+	ts.get(ctx, "/playlists/"+url.PathEscape(playlistId)+"?countryCode="+ts.countryCode, &document)
+
+	var playlist tidalResource
+	err := json.Unmarshal(document.Data, &playlist)
+	if err != nil {
+		panic(err)
+	}
+
+	return Playlist{ID: playlistId, Name: playlist.Attributes.Name}
+}
+
+func (ts *TidalService) GetTracksSince(ctx context.Context, playlistId string, after time.Time) []Track {
+	var playlistContents []Track
+
+	next := "/playlists/" + url.PathEscape(playlistId) + "/relationships/items?countryCode=" + ts.countryCode + "&include=items,items.artists"
+	for next != "" {
+		var document tidalDocument
+		ts.get(ctx, next, &document)
+
+		var items []tidalResource
+		err := json.Unmarshal(document.Data, &items)
+		if err != nil {
+			panic(err)
+		}
+
+		included := make(map[string]tidalResource)
+		for _, resource := range document.Included {
+			included[resource.Type+"/"+resource.ID] = resource
+		}
+
+		for _, item := range items {
+			if item.Type != "tracks" || !item.Meta.AddedAt.After(after) {
+				continue
+			}
+
+			track := included["tracks/"+item.ID]
+			var artistsFull []string
+			for _, artist := range track.Relationships.Artists.Data {
+				artistsFull = append(artistsFull, included["artists/"+artist.ID].Attributes.Name)
+			}
+
+			entry := Track{
+				ID:       item.ID,
+				Artists:  artistsFull,
+				Title:    track.Attributes.Title,
+				Duration: parseIsoDuration(track.Attributes.Duration),
+				AddedAt:  item.Meta.AddedAt,
+			}
+			playlistContents = append(playlistContents, entry)
+		}
+		next = document.Links.Next
+	}
+
+	return playlistContents
+}
+
+func (ts *TidalService) get(ctx context.Context, endpoint string, target any) {
+	request, err := http.NewRequestWithContext(ctx, "GET", ts.httpHost+endpoint, nil)
+	if err != nil {
+		panic(err)
+	}
+	request.Header.Set("Accept", "application/vnd.api+json")
+
+	response, err := ts.httpClient.Do(request)
+	if err != nil {
+		panic(err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			panic(err)
+		}
+	}(response.Body)
+
+	body, _ := io.ReadAll(response.Body)
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		panic(err)
+	}
+}
+
+var isoDuration = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// parseIsoDuration reads the "PT3M25S" durations Tidal uses.
+func parseIsoDuration(value string) time.Duration {
+	match := isoDuration.FindStringSubmatch(value)
+	if match == nil {
+		return 0
+	}
+
+	var duration time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		amount, _ := strconv.Atoi(match[i+1])
+		duration += time.Duration(amount) * unit
+	}
+
+	return duration
+}
@@ -0,0 +1,82 @@
+package Events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	TrackDiscovered   Type = "TrackDiscovered"
+	TrackSkipped      Type = "TrackSkipped"
+	SearchStarted     Type = "SearchStarted"
+	MatchSelected     Type = "MatchSelected"
+	DownloadCompleted Type = "DownloadCompleted"
+	DownloadFailed    Type = "DownloadFailed"
+	TrackWishlisted   Type = "TrackWishlisted"
+	TrackRemoved      Type = "TrackRemoved"
+	PlaylistRenamed   Type = "PlaylistRenamed"
+	BurstHeld         Type = "BurstHeld"
+)
+
+// Event is something that happened to a track on its way through the pipeline,
+// or to the playlist itself when Track is empty.
+type Event struct {
+	Type   Type      `json:"type"`
+	Track  string    `json:"track"`
+	Detail string    `json:"detail,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Bus hands every published event to all subscribers, in the order they subscribed.
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers []func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(subscriber func(Event)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subscribers = append(b.subscribers, subscriber)
+}
+
+// Publish delivers the event synchronously, subscribers that block hold up the pipeline.
+func (b *Bus) Publish(eventType Type, track string, detail string) {
+	event := Event{Type: eventType, Track: track, Detail: detail, Time: time.Now()}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, subscriber := range b.subscribers {
+		subscriber(event)
+	}
+}
+
+// AuditLog returns a subscriber appending every event to path as a line of JSON.
+func AuditLog(path string) (func(Event), error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	encoder := json.NewEncoder(file)
+
+	return func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		err := encoder.Encode(event)
+		if err != nil {
+			fmt.Printf("Could not write to the audit log: %s\n", err)
+		}
+	}, nil
+}
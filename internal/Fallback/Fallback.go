@@ -0,0 +1,22 @@
+package Fallback
+
+import "context"
+
+// Downloader fetches a track from somewhere other than Soulseek once the
+// regular pipeline gave up on it.
+type Downloader interface {
+	// Name identifies the source in the download history.
+	Name() string
+	// Download fetches the best match for query and returns the local path of the file.
+	Download(ctx context.Context, query string) (string, error)
+}
+
+// New returns the fallback downloader selected by name, or nil when none is configured.
+func New(name string, command string, outputDir string) Downloader {
+	switch name {
+	case "ytdlp", "yt-dlp":
+		return NewYtDlp(command, outputDir)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package Fallback
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// YtDlpService downloads the first YouTube search hit as MP3 using yt-dlp.
+// The command may be a plain binary or a full container invocation,
+// e.g. "docker run --rm -v /music:/music jauderho/yt-dlp".
+type YtDlpService struct {
+	command   []string
+	outputDir string
+}
+
+func NewYtDlp(command string, outputDir string) *YtDlpService {
+	if command == "" {
+		command = "yt-dlp"
+	}
+
+	return &YtDlpService{
+		command:   strings.Fields(command),
+		outputDir: outputDir,
+	}
+}
+
+func (yt *YtDlpService) Name() string {
+	return "yt-dlp"
+}
+
+func (yt *YtDlpService) Download(ctx context.Context, query string) (string, error) {
+	args := append([]string{}, yt.command[1:]...)
+	args = append(args,
+		"--extract-audio",
+		"--audio-format", "mp3",
+		"--no-playlist",
+		"--no-simulate",
+		"--print", "after_move:filepath",
+		"--output", filepath.Join(yt.outputDir, "%(title)s.%(ext)s"),
+		"ytsearch1:"+query,
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, yt.command[0], args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("yt-dlp failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	path := strings.TrimSpace(lines[len(lines)-1])
+	if path == "" {
+		return "", fmt.Errorf("yt-dlp found nothing for '%s'", query)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,40 @@
+package Integrations
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// BeetsService imports single tracks with `beet import`. The command may point
+// into a container, e.g. "docker exec beets beet".
+type BeetsService struct {
+	command []string
+}
+
+func NewBeets(command string) *BeetsService {
+	if command == "" {
+		command = "beet"
+	}
+
+	return &BeetsService{
+		command: strings.Fields(command),
+	}
+}
+
+func (bs *BeetsService) Name() string {
+	return "beets"
+}
+
+func (bs *BeetsService) Import(path string) error {
+	args := append([]string{}, bs.command[1:]...)
+	// copy even when the beets config moves files, the download has to stay in place
+	args = append(args, "import", "--quiet", "--singletons", "--copy", path)
+
+	output, err := exec.Command(bs.command[0], args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("beet import failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
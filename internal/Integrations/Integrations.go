@@ -0,0 +1,39 @@
+package Integrations
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Integration hands a verified download over to a music library manager.
+type Integration interface {
+	Name() string
+	Import(path string) error
+}
+
+// FromEnv builds the integrations listed in the comma separated INTEGRATIONS variable.
+func FromEnv() []Integration {
+	refreshDelay := 60 * time.Second
+	seconds, err := strconv.Atoi(os.Getenv("LIBRARY_REFRESH_DELAY"))
+	if err == nil {
+		refreshDelay = time.Duration(seconds) * time.Second
+	}
+
+	var integrations []Integration
+	for _, name := range strings.Split(os.Getenv("INTEGRATIONS"), ",") {
+		switch strings.TrimSpace(name) {
+		case "lidarr":
+			integrations = append(integrations, NewLidarr(os.Getenv("LIDARR_URL"), os.Getenv("LIDARR_API_KEY")))
+		case "beets":
+			integrations = append(integrations, NewBeets(os.Getenv("BEETS_COMMAND")))
+		case "plex":
+			integrations = append(integrations, NewPlex(os.Getenv("PLEX_URL"), os.Getenv("PLEX_TOKEN"), os.Getenv("PLEX_SECTION_ID"), refreshDelay))
+		case "jellyfin":
+			integrations = append(integrations, NewJellyfin(os.Getenv("JELLYFIN_URL"), os.Getenv("JELLYFIN_API_KEY"), refreshDelay))
+		}
+	}
+
+	return integrations
+}
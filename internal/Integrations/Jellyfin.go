@@ -0,0 +1,61 @@
+package Integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type JellyfinService struct {
+	libraryRefresh
+	httpHost   string
+	apiKey     string
+	httpClient http.Client
+}
+
+func NewJellyfin(host string, apiKey string, delay time.Duration) *JellyfinService {
+	js := &JellyfinService{
+		httpHost:   host,
+		apiKey:     apiKey,
+		httpClient: http.Client{},
+	}
+	js.libraryRefresh = libraryRefresh{name: "jellyfin", delay: delay, refresh: js.refresh}
+
+	return js
+}
+
+// refresh reports the changed folders so Jellyfin rescans just those paths.
+func (js *JellyfinService) refresh(dirs []string) error {
+	apiEndpoint := "/Library/Media/Updated"
+
+	var updates []map[string]string
+	for _, dir := range dirs {
+		updates = append(updates, map[string]string{"Path": dir, "UpdateType": "Modified"})
+	}
+
+	jsonRaw, err := json.Marshal(map[string]any{"Updates": updates})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", js.httpHost+apiEndpoint, bytes.NewBuffer(jsonRaw))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set("X-Emby-Token", js.apiKey)
+
+	response, err := js.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("jellyfin responded with HTTP %s", response.Status)
+	}
+
+	return nil
+}
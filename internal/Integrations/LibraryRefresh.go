@@ -0,0 +1,55 @@
+package Integrations
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// libraryRefresh collects download folders and triggers a single refresh once
+// no new download arrived for the configured delay, so a batch causes one scan.
+type libraryRefresh struct {
+	name    string
+	delay   time.Duration
+	refresh func(dirs []string) error
+	mutex   sync.Mutex
+	dirs    map[string]bool
+	timer   *time.Timer
+}
+
+func (lr *libraryRefresh) Name() string {
+	return lr.name
+}
+
+func (lr *libraryRefresh) Import(path string) error {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+
+	if lr.dirs == nil {
+		lr.dirs = make(map[string]bool)
+	}
+	lr.dirs[filepath.Dir(path)] = true
+
+	if lr.timer != nil {
+		lr.timer.Stop()
+	}
+	lr.timer = time.AfterFunc(lr.delay, lr.flush)
+
+	return nil
+}
+
+func (lr *libraryRefresh) flush() {
+	lr.mutex.Lock()
+	var dirs []string
+	for dir := range lr.dirs {
+		dirs = append(dirs, dir)
+	}
+	lr.dirs = nil
+	lr.mutex.Unlock()
+
+	err := lr.refresh(dirs)
+	if err != nil {
+		fmt.Printf("%s library refresh failed: %s\n", lr.name, err)
+	}
+}
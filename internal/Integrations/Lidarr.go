@@ -0,0 +1,63 @@
+package Integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+)
+
+type LidarrService struct {
+	httpHost   string
+	apiKey     string
+	httpClient http.Client
+}
+
+func NewLidarr(host string, apiKey string) *LidarrService {
+	return &LidarrService{
+		httpHost:   host,
+		apiKey:     apiKey,
+		httpClient: http.Client{},
+	}
+}
+
+func (ls *LidarrService) Name() string {
+	return "lidarr"
+}
+
+// Import asks Lidarr to scan the folder the track was downloaded to. Lidarr copies
+// the file, the history and playlist file keep pointing at the download.
+func (ls *LidarrService) Import(path string) error {
+	apiEndpoint := "/api/v1/command"
+
+	jsonRaw, err := json.Marshal(map[string]any{
+		"name":       "DownloadedAlbumsScan",
+		"path":       filepath.Dir(path),
+		"importMode": "Copy",
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", ls.httpHost+apiEndpoint, bytes.NewBuffer(jsonRaw))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	request.Header.Set("X-Api-Key", ls.apiKey)
+
+	response, err := ls.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		body, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("lidarr responded with HTTP %s: %s", response.Status, body)
+	}
+
+	return nil
+}
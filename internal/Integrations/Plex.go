@@ -0,0 +1,56 @@
+package Integrations
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type PlexService struct {
+	libraryRefresh
+	httpHost   string
+	token      string
+	sectionId  string
+	httpClient http.Client
+}
+
+func NewPlex(host string, token string, sectionId string, delay time.Duration) *PlexService {
+	ps := &PlexService{
+		httpHost:   host,
+		token:      token,
+		sectionId:  sectionId,
+		httpClient: http.Client{},
+	}
+	ps.libraryRefresh = libraryRefresh{name: "plex", delay: delay, refresh: ps.refresh}
+
+	return ps
+}
+
+// refresh scans only the folders that received new files instead of the whole section.
+func (ps *PlexService) refresh(dirs []string) error {
+	apiEndpoint := "/library/sections/" + url.PathEscape(ps.sectionId) + "/refresh"
+
+	for _, dir := range dirs {
+		query := url.Values{}
+		query.Set("path", dir)
+		query.Set("X-Plex-Token", ps.token)
+
+		request, err := http.NewRequest("GET", ps.httpHost+apiEndpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := ps.httpClient.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		if response.StatusCode >= 300 {
+			return fmt.Errorf("plex responded with HTTP %s", response.Status)
+		}
+	}
+
+	return nil
+}
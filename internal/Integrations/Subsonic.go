@@ -0,0 +1,151 @@
+package Integrations
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PlaylistTrack is a downloaded playlist track to look up on a media server.
+type PlaylistTrack struct {
+	Artist string
+	Title  string
+}
+
+// SubsonicService keeps a playlist on a Subsonic compatible server like Navidrome in
+// sync with the source playlist. Tracks are found by searching the server, so they
+// only show up once it scanned the files.
+type SubsonicService struct {
+	httpHost   string
+	username   string
+	password   string
+	httpClient http.Client
+}
+
+func NewSubsonic(host string, username string, password string) *SubsonicService {
+	return &SubsonicService{
+		httpHost:   strings.TrimSuffix(host, "/"),
+		username:   username,
+		password:   password,
+		httpClient: http.Client{},
+	}
+}
+
+type subsonicResponse struct {
+	Response struct {
+		Status string `json:"status"`
+		Error  struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		SearchResult3 struct {
+			Song []struct {
+				ID     string `json:"id"`
+				Title  string `json:"title"`
+				Artist string `json:"artist"`
+			} `json:"song"`
+		} `json:"searchResult3"`
+		Playlists struct {
+			Playlist []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"playlist"`
+		} `json:"playlists"`
+	} `json:"subsonic-response"`
+}
+
+// call sends a request with token authentication, the parameters are posted as a
+// form so long playlists do not run into URL length limits.
+func (ss *SubsonicService) call(method string, params url.Values) (subsonicResponse, error) {
+	var result subsonicResponse
+
+	salt := make([]byte, 8)
+	rand.Read(salt)
+	hash := md5.Sum([]byte(ss.password + hex.EncodeToString(salt)))
+	params.Set("u", ss.username)
+	params.Set("s", hex.EncodeToString(salt))
+	params.Set("t", hex.EncodeToString(hash[:]))
+	params.Set("v", "1.16.1")
+	params.Set("c", "spotiseek")
+	params.Set("f", "json")
+
+	response, err := ss.httpClient.PostForm(ss.httpHost+"/rest/"+method, params)
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return result, fmt.Errorf("subsonic responded with HTTP %s", response.Status)
+	}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	if err != nil {
+		return result, err
+	}
+	if result.Response.Status != "ok" {
+		return result, fmt.Errorf("subsonic %s failed: %s", method, result.Response.Error.Message)
+	}
+
+	return result, nil
+}
+
+// findSong returns the id of the song with the title by the artist, if the server has it.
+func (ss *SubsonicService) findSong(track PlaylistTrack) (string, bool, error) {
+	result, err := ss.call("search3", url.Values{
+		"query":       {track.Title},
+		"songCount":   {"50"},
+		"artistCount": {"0"},
+		"albumCount":  {"0"},
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, song := range result.Response.SearchResult3.Song {
+		if strings.EqualFold(song.Title, track.Title) && strings.Contains(strings.ToLower(song.Artist), strings.ToLower(track.Artist)) {
+			return song.ID, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// PushPlaylist replaces the songs of the playlist with the given name, creating it
+// when missing. It returns how many of the tracks the server did not know yet.
+func (ss *SubsonicService) PushPlaylist(name string, tracks []PlaylistTrack) (int, error) {
+	params := url.Values{}
+	missing := 0
+	for _, track := range tracks {
+		id, ok, err := ss.findSong(track)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			missing++
+			continue
+		}
+		params.Add("songId", id)
+	}
+
+	playlists, err := ss.call("getPlaylists", url.Values{})
+	if err != nil {
+		return 0, err
+	}
+	params.Set("name", name)
+	for _, playlist := range playlists.Response.Playlists.Playlist {
+		if playlist.Name == name {
+			params.Del("name")
+			params.Set("playlistId", playlist.ID)
+			break
+		}
+	}
+
+	_, err = ss.call("createPlaylist", params)
+
+	return missing, err
+}
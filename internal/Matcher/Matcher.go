@@ -0,0 +1,218 @@
+package Matcher
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Candidate is a file offered by a Soulseek user for a search.
+type Candidate struct {
+	Filename string
+	// Length is the duration reported by the peer, zero when unknown.
+	Length time.Duration
+}
+
+// Matcher scores how well a candidate file fits a "<artist> <title>" query,
+// from 0 for unrelated files to 1 for a perfect match.
+type Matcher interface {
+	Name() string
+	Score(query string, duration time.Duration, candidate Candidate) float64
+}
+
+// Names lists the matchers New accepts.
+var Names = []string{"overlap", "levenshtein", "tokenset", "duration"}
+
+// New returns the matcher selected by name, defaulting to word overlap.
+func New(name string) Matcher {
+	switch name {
+	case "levenshtein":
+		return Levenshtein{}
+	case "tokenset":
+		return TokenSet{}
+	case "duration":
+		return DurationWeighted{Matcher: TokenSet{}, Tolerance: 30 * time.Second}
+	}
+
+	return Overlap{}
+}
+
+// Overlap is the share of query words that appear in the file name.
+type Overlap struct{}
+
+func (Overlap) Name() string { return "overlap" }
+
+func (Overlap) Score(query string, _ time.Duration, candidate Candidate) float64 {
+	words := tokens(query)
+	if len(words) == 0 {
+		return 0
+	}
+
+	present := make(map[string]bool)
+	for _, word := range tokens(baseName(candidate.Filename)) {
+		present[word] = true
+	}
+	found := 0
+	for _, word := range words {
+		if present[word] {
+			found++
+		}
+	}
+
+	return float64(found) / float64(len(words))
+}
+
+// Levenshtein compares the normalized query and file name by edit distance.
+type Levenshtein struct{}
+
+func (Levenshtein) Name() string { return "levenshtein" }
+
+func (Levenshtein) Score(query string, _ time.Duration, candidate Candidate) float64 {
+	return ratio(strings.Join(tokens(query), " "), strings.Join(tokens(baseName(candidate.Filename)), " "))
+}
+
+// TokenSet is the token set ratio known from fuzzywuzzy, which ignores word order
+// and words that only one side contains, such as track numbers or "feat." credits.
+type TokenSet struct{}
+
+func (TokenSet) Name() string { return "tokenset" }
+
+func (TokenSet) Score(query string, _ time.Duration, candidate Candidate) float64 {
+	a := uniqueSorted(tokens(query))
+	b := uniqueSorted(tokens(baseName(candidate.Filename)))
+
+	inB := make(map[string]bool)
+	for _, word := range b {
+		inB[word] = true
+	}
+	var common, onlyA, onlyB []string
+	for _, word := range a {
+		if inB[word] {
+			common = append(common, word)
+			delete(inB, word)
+		} else {
+			onlyA = append(onlyA, word)
+		}
+	}
+	for _, word := range b {
+		if inB[word] {
+			onlyB = append(onlyB, word)
+		}
+	}
+
+	intersection := strings.Join(common, " ")
+	withA := strings.TrimSpace(intersection + " " + strings.Join(onlyA, " "))
+	withB := strings.TrimSpace(intersection + " " + strings.Join(onlyB, " "))
+
+	best := ratio(withA, withB)
+	if intersection != "" {
+		best = highest(best, ratio(intersection, withA), ratio(intersection, withB))
+	}
+
+	return best
+}
+
+// DurationWeighted lowers the score of another matcher for files whose length is
+// further than Tolerance away from the expected duration.
+type DurationWeighted struct {
+	Matcher   Matcher
+	Tolerance time.Duration
+}
+
+func (d DurationWeighted) Name() string { return "duration" }
+
+func (d DurationWeighted) Score(query string, duration time.Duration, candidate Candidate) float64 {
+	score := d.Matcher.Score(query, duration, candidate)
+	if duration == 0 || candidate.Length == 0 || d.Tolerance <= 0 {
+		return score
+	}
+
+	difference := duration - candidate.Length
+	if difference < 0 {
+		difference = -difference
+	}
+	weight := 1 - float64(difference)/float64(d.Tolerance)
+	if weight < 0 {
+		return 0
+	}
+
+	return score * weight
+}
+
+// baseName strips the remote directory and extension, which Soulseek separates with backslashes.
+func baseName(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func uniqueSorted(words []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			unique = append(unique, word)
+		}
+	}
+	sort.Strings(unique)
+
+	return unique
+}
+
+// ratio is 1 minus the edit distance relative to the longer string.
+func ratio(a string, b string) float64 {
+	x, y := []rune(a), []rune(b)
+	if len(x) == 0 && len(y) == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(y)+1)
+	current := make([]int, len(y)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		current[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			current[j] = lowest(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return 1 - float64(previous[len(y)])/float64(highest(len(x), len(y)))
+}
+
+func lowest[T int | float64](values ...T) T {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+
+	return result
+}
+
+func highest[T int | float64](values ...T) T {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value > result {
+			result = value
+		}
+	}
+
+	return result
+}
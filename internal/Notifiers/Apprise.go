@@ -0,0 +1,62 @@
+package Notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// AppriseService posts to an Apprise API notify endpoint, e.g.
+// http://apprise:8000/notify/spotiseek for a stored configuration. With urls set
+// the notification goes to those Apprise URLs instead.
+type AppriseService struct {
+	endpoint   string
+	urls       string
+	httpClient http.Client
+}
+
+func NewApprise(endpoint string, urls string) *AppriseService {
+	return &AppriseService{
+		endpoint:   endpoint,
+		urls:       urls,
+		httpClient: http.Client{},
+	}
+}
+
+func (as *AppriseService) Name() string {
+	return "apprise"
+}
+
+func (as *AppriseService) Notify(notification Notification) error {
+	payload := map[string]any{
+		"title": notification.Title,
+		"body":  notification.Text,
+		"type":  "info",
+	}
+	if as.urls != "" {
+		payload["urls"] = as.urls
+	}
+	jsonRaw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", as.endpoint, bytes.NewBuffer(jsonRaw))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := as.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("apprise responded with HTTP %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package Notifiers
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// EmailService sends notifications over SMTP, as HTML with a plain text alternative
+// when the notification has both.
+type EmailService struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// NewEmail sends from from to the comma separated recipients in to. The server
+// is only authenticated with when username is set.
+func NewEmail(host string, port string, username string, password string, from string, to string) *EmailService {
+	var recipients []string
+	for _, recipient := range strings.Split(to, ",") {
+		if strings.TrimSpace(recipient) != "" {
+			recipients = append(recipients, strings.TrimSpace(recipient))
+		}
+	}
+	if from == "" {
+		from = username
+	}
+
+	return &EmailService{
+		addr:     net.JoinHostPort(host, port),
+		username: username,
+		password: password,
+		from:     from,
+		to:       recipients,
+	}
+}
+
+func (es *EmailService) Name() string {
+	return "email"
+}
+
+func (es *EmailService) Notify(notification Notification) error {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", es.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(es.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Title))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+
+	if notification.HTML == "" {
+		fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n%s", notification.Text)
+	} else {
+		body := multipart.NewWriter(&message)
+		fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", body.Boundary())
+		for _, part := range []struct{ contentType, content string }{
+			{"text/plain; charset=utf-8", notification.Text},
+			{"text/html; charset=utf-8", notification.HTML},
+		} {
+			writer, err := body.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+			if err != nil {
+				return err
+			}
+			writer.Write([]byte(part.content))
+		}
+		body.Close()
+	}
+
+	var auth smtp.Auth
+	if es.username != "" {
+		host, _, _ := net.SplitHostPort(es.addr)
+		auth = smtp.PlainAuth("", es.username, es.password, host)
+	}
+	err := smtp.SendMail(es.addr, auth, es.from, es.to, message.Bytes())
+	if err != nil {
+		return fmt.Errorf("sending the email failed: %w", err)
+	}
+
+	return nil
+}
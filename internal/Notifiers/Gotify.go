@@ -0,0 +1,58 @@
+package Notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GotifyService posts to a Gotify server with an application token.
+type GotifyService struct {
+	httpHost   string
+	token      string
+	httpClient http.Client
+}
+
+func NewGotify(host string, token string) *GotifyService {
+	return &GotifyService{
+		httpHost:   strings.TrimSuffix(host, "/"),
+		token:      token,
+		httpClient: http.Client{},
+	}
+}
+
+func (gs *GotifyService) Name() string {
+	return "gotify"
+}
+
+func (gs *GotifyService) Notify(notification Notification) error {
+	jsonRaw, err := json.Marshal(map[string]any{
+		"title":    notification.Title,
+		"message":  notification.Text,
+		"priority": 5,
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", gs.httpHost+"/message?token="+url.QueryEscape(gs.token), bytes.NewBuffer(jsonRaw))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	response, err := gs.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("gotify responded with HTTP %s", response.Status)
+	}
+
+	return nil
+}
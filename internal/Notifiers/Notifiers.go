@@ -0,0 +1,45 @@
+package Notifiers
+
+import (
+	"os"
+	"strings"
+)
+
+// Notification is a message for the user, HTML is optional and only used by
+// targets that can show it.
+type Notification struct {
+	Title string
+	Text  string
+	HTML  string
+}
+
+// Notifier delivers notifications to one target.
+type Notifier interface {
+	Name() string
+	Notify(notification Notification) error
+}
+
+// FromEnv builds the targets listed in the comma separated NOTIFY variable.
+func FromEnv() []Notifier {
+	var notifiers []Notifier
+	for _, name := range strings.Split(os.Getenv("NOTIFY"), ",") {
+		switch strings.TrimSpace(name) {
+		case "email":
+			notifiers = append(notifiers, EmailFromEnv())
+		case "gotify":
+			notifiers = append(notifiers, NewGotify(os.Getenv("GOTIFY_URL"), os.Getenv("GOTIFY_TOKEN")))
+		case "ntfy":
+			notifiers = append(notifiers, NewNtfy(os.Getenv("NTFY_URL"), os.Getenv("NTFY_TOPIC"), os.Getenv("NTFY_TOKEN")))
+		case "apprise":
+			notifiers = append(notifiers, NewApprise(os.Getenv("APPRISE_URL"), os.Getenv("APPRISE_URLS")))
+		}
+	}
+
+	return notifiers
+}
+
+// EmailFromEnv builds the email target from the SMTP_ variables.
+func EmailFromEnv() *EmailService {
+	return NewEmail(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USER"),
+		os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM"), os.Getenv("SMTP_TO"))
+}
@@ -0,0 +1,57 @@
+package Notifiers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NtfyService publishes to a topic on ntfy.sh or a self-hosted ntfy server. The token
+// is only needed for topics that require authentication.
+type NtfyService struct {
+	httpHost   string
+	topic      string
+	token      string
+	httpClient http.Client
+}
+
+func NewNtfy(host string, topic string, token string) *NtfyService {
+	if host == "" {
+		host = "https://ntfy.sh"
+	}
+
+	return &NtfyService{
+		httpHost:   strings.TrimSuffix(host, "/"),
+		topic:      topic,
+		token:      token,
+		httpClient: http.Client{},
+	}
+}
+
+func (ns *NtfyService) Name() string {
+	return "ntfy"
+}
+
+func (ns *NtfyService) Notify(notification Notification) error {
+	request, err := http.NewRequest("POST", ns.httpHost+"/"+url.PathEscape(ns.topic), strings.NewReader(notification.Text))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Title", notification.Title)
+	if ns.token != "" {
+		request.Header.Set("Authorization", "Bearer "+ns.token)
+	}
+
+	response, err := ns.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("ntfy responded with HTTP %s", response.Status)
+	}
+
+	return nil
+}
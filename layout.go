@@ -0,0 +1,90 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// folderTemplate lays out downloads under SLSKD_DOWNLOAD_DIR, e.g.
+// "{artist}/{album}/{artist} - {title}". Empty keeps the folders slskd creates.
+var folderTemplate string
+
+// organize moves a downloaded file to the place FOLDER_TEMPLATE gives it and returns
+// its new path, or the old one when no template is set or the move failed.
+func organize(track ApiClients.Track, path string) string {
+	if folderTemplate == "" {
+		return path
+	}
+
+	target := filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), expandTemplate(folderTemplate, track)+strings.ToLower(filepath.Ext(path)))
+	if target == path {
+		return path
+	}
+	err := os.MkdirAll(filepath.Dir(target), 0777)
+	if err == nil {
+		// two tracks can share a name, e.g. a song and its live version
+		target = freePath(target)
+		err = os.Rename(path, target)
+	}
+	if err != nil {
+		// the target may be on another volume
+		err = linkOrCopy(path, target)
+		if err == nil {
+			err = os.Remove(path)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Could not move %s to %s: %s\n", path, target, err)
+		return path
+	}
+
+	// slskd creates a folder per download, drop it once it is empty
+	os.Remove(filepath.Dir(path))
+	return target
+}
+
+// freePath returns path, or when a file already exists there, the first free
+// "name (2).ext", "name (3).ext" and so on next to it.
+func freePath(path string) string {
+	extension := filepath.Ext(path)
+	base := strings.TrimSuffix(path, extension)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s (%d)%s", base, i, extension)
+	}
+}
+
+// expandTemplate fills in {playlist}, {artist}, {artists}, {album}, {year}, {title}
+// and {track}, which is the same as {title}. Values are made safe for file names.
+func expandTemplate(template string, track ApiClients.Track) string {
+	artist := "Unknown Artist"
+	if len(track.Artists) > 0 {
+		artist = track.Artists[0]
+	}
+	album := track.Album
+	if album == "" {
+		album = "Unknown Album"
+	}
+	year := ""
+	if track.Year > 0 {
+		year = strconv.Itoa(track.Year)
+	}
+
+	replacer := strings.NewReplacer(
+		"{playlist}", safeFilename(playlistName),
+		"{artist}", safeFilename(artist),
+		"{artists}", safeFilename(strings.Join(track.Artists, ", ")),
+		"{album}", safeFilename(album),
+		"{year}", year,
+		"{title}", safeFilename(track.Title),
+		"{track}", safeFilename(track.Title),
+	)
+
+	return filepath.FromSlash(replacer.Replace(template))
+}
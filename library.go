@@ -0,0 +1,136 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type libraryFile struct {
+	path string
+	// normalized file name and, when the file is tagged, normalized "<artist><title>"
+	name   string
+	tagKey string
+}
+
+// Library indexes an existing music collection so tracks the user already owns are not downloaded.
+type Library struct {
+	root string
+	// skip names folders below root that are not scanned
+	skip  map[string]bool
+	mutex sync.RWMutex
+	files []libraryFile
+}
+
+func NewLibrary(root string) *Library {
+	return &Library{root: root}
+}
+
+var audioExtensions = map[string]bool{".mp3": true, ".flac": true, ".m4a": true, ".ogg": true, ".opus": true, ".wav": true}
+
+// Scan rebuilds the index from the files under the library root.
+func (l *Library) Scan() {
+	var files []libraryFile
+	filepath.WalkDir(l.root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && path != l.root && l.skip[entry.Name()] {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() || !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		files = append(files, indexLibraryFile(path))
+		return nil
+	})
+
+	l.mutex.Lock()
+	l.files = files
+	l.mutex.Unlock()
+	fmt.Printf("Indexed %d files in the library at %s\n", len(files), l.root)
+}
+
+func indexLibraryFile(path string) libraryFile {
+	file := libraryFile{
+		path: path,
+		name: normalize(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))),
+	}
+	artist, title := readTags(path)
+	if artist != "" && title != "" {
+		file.tagKey = normalize(artist + title)
+	}
+
+	return file
+}
+
+// Find returns a library file that is most likely the given track: matching tags
+// or a file name containing both the first artist and the title.
+func (l *Library) Find(track ApiClients.Track) (string, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	key := normalizedKey(track)
+	title := normalize(track.Title)
+	artist := ""
+	if len(track.Artists) > 0 {
+		artist = normalize(track.Artists[0])
+	}
+
+	for _, file := range l.files {
+		if file.tagKey != "" && file.tagKey == key {
+			return file.path, true
+		}
+	}
+	if title == "" || artist == "" {
+		return "", false
+	}
+	for _, file := range l.files {
+		if strings.Contains(file.name, artist) && strings.Contains(file.name, title) {
+			return file.path, true
+		}
+	}
+
+	return "", false
+}
+
+// reuseFromLibrary skips tracks found in the library, linking them into the download
+// folder when LIBRARY_LINK=1. Unlinked library files are never recorded as downloads
+// so nothing ever moves or deletes them.
+func reuseFromLibrary(track ApiClients.Track) bool {
+	if library == nil {
+		return false
+	}
+	path, ok := library.Find(track)
+	if !ok {
+		return false
+	}
+
+	if !libraryLink {
+		fmt.Printf("'%s' is already in the library as %s\n", track.Query(), path)
+		return true
+	}
+
+	target := filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), "library", filepath.Base(path))
+	err := linkOrCopy(path, target)
+	if err != nil {
+		fmt.Printf("Could not link %s from the library: %s\n", path, err)
+		return false
+	}
+
+	fmt.Printf("Linked '%s' from the library\n", track.Query())
+	onDownloaded(track, target, "library")
+	return true
+}
+
+func normalize(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, text)
+}
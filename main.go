@@ -2,47 +2,135 @@ package main
 
 import (
 	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Events"
+	"Spotiseek2/internal/Fallback"
+	"Spotiseek2/internal/Integrations"
+	"Spotiseek2/internal/Matcher"
+	"Spotiseek2/internal/Notifiers"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
-func checkPlaylistContents(queue chan string, spotify *ApiClients.SpotifyService, tracklistId string) {
+func checkPlaylistContents(ctx context.Context, queue chan ApiClients.Track, source ApiClients.PlaylistSource, tracklistId string) {
 	fmt.Println("Checking for new tracks on the playlist")
-	playlistTracks := spotify.GetPlaylistTracks(tracklistId, lastPlaylistCheck)
+	followPlaylistRename(ctx, source, tracklistId)
+	applyImports()
+	queueRedownloads(ctx, queue)
+	queuePending(ctx, queue)
+	queueWishlist(ctx, queue)
+	checkForMissingFiles(ctx, queue)
+	if mirrorMode {
+		mirrorRemovals(ctx, source, tracklistId)
+	}
+
+	playlistTracks := unseenTracks(source.GetTracksSince(ctx, tracklistId, lastPlaylistCheck))
 	for i := range playlistTracks {
-		fmt.Printf("Found the following: %s\n", playlistTracks[i])
-		queue <- playlistTracks[i]
+		fmt.Printf("Found the following: %s\n", playlistTracks[i].Query())
+		events.Publish(Events.TrackDiscovered, playlistTracks[i].Query(), "")
+	}
+	if !holdBurst(playlistTracks) {
+		enqueueTracks(ctx, queue, playlistTracks)
+	}
+	if len(playlistTracks) > 0 {
+		lastPlaylistChange = time.Now()
 	}
 	lastPlaylistCheck = time.Now()
-	os.WriteFile("timestamp", []byte(lastPlaylistCheck.String()), 0666)
+	recordPoll()
+	os.WriteFile("timestamp", []byte(lastPlaylistCheck.Format(time.RFC3339)), 0666)
+
+	changed := playlistChanged.Swap(false)
+	if playlistFile && changed {
+		err := writePlaylistFile(ctx, source, tracklistId)
+		if err != nil {
+			fmt.Printf("Could not write the playlist file: %s\n", err)
+		}
+	}
+	if playlistSnapshots && (changed || !fileExists(snapshotPath())) {
+		err := writeSnapshot(ctx, source, tracklistId)
+		if err != nil {
+			fmt.Printf("Could not write the playlist snapshot: %s\n", err)
+		}
+	}
+	if subsonic != nil && (changed || subsonicBehind) {
+		behind, err := pushPlaylist(ctx, source, tracklistId)
+		if err != nil {
+			fmt.Printf("Could not push the playlist to the Subsonic server: %s\n", err)
+		}
+		subsonicBehind = behind || err != nil
+	}
 }
 
-func searchForQueueItems(queue chan string, soulseek ApiClients.Soulseek) {
+func searchForQueueItems(ctx context.Context, queue chan ApiClients.Track, soulseek ApiClients.Soulseek) {
 	for {
 		select {
-		case query := <-queue:
-			fmt.Printf("Searching for '%s'\n", query)
-			searchResult := soulseek.Search(query)
-			go spawnSearchObserver(searchResult, soulseek)
+		case <-ctx.Done():
+			return
+		case <-draining:
+			return
+		case track := <-queue:
+			tracksProcessed.Add(1)
+			history.Remember(track)
+			if isSkipped(track) {
+				fmt.Printf("Skipping '%s'\n", track.Query())
+				events.Publish(Events.TrackSkipped, track.Query(), "on the skip list")
+				continue
+			}
+			if reuseExistingDownload(track) {
+				continue
+			}
+			if takeFromAlbumFolder(ctx, track, soulseek, queue) {
+				continue
+			}
+			if !waitForCapacity(ctx, soulseek) || !searchLimit.Wait(ctx) {
+				keepPending(track)
+				return
+			}
+			if !startSearch() {
+				keepPending(track)
+				return
+			}
+			fmt.Printf("Searching for '%s'\n", track.Query())
+			events.Publish(Events.SearchStarted, track.Query(), "")
+			searchResult, err := soulseek.Search(ctx, searchQuery(track))
+			if err != nil {
+				inFlight.Done()
+				go failDownload(ctx, track, err, queue)
+				continue
+			}
+			go spawnSearchObserver(ctx, track, searchResult, soulseek, queue)
 		}
 	}
 }
 
-func spawnSearchObserver(result ApiClients.SearchResult, soulseek ApiClients.Soulseek) {
+func spawnSearchObserver(ctx context.Context, track ApiClients.Track, result ApiClients.SearchResult, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) {
 	done := make(chan bool)
 
 	timer := time.NewTicker(5 * time.Second)
 	go func() {
+		defer timer.Stop()
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case <-timer.C:
 				fmt.Printf("%s, 5 sekund później: %s\n", result.SearchText, result.State)
-				result = soulseek.GetSearchResult(result.ID)
+				polled, err := soulseek.GetSearchResult(ctx, result.ID)
+				if err != nil {
+					fmt.Printf("Could not poll the search for '%s': %s\n", track.Query(), err)
+					continue
+				}
+				result = polled
 				if strings.Contains(result.State, "Completed") {
 					done <- true
 					return
@@ -52,14 +140,34 @@ func spawnSearchObserver(result ApiClients.SearchResult, soulseek ApiClients.Sou
 	}()
 
 	go func() {
+		defer inFlight.Done()
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case status := <-done:
+				if status && result.ResponseCount == 0 {
+					failDownload(ctx, track, errNoResults, queue)
+					return
+				}
 				if status && result.ResponseCount > 0 {
-					result = soulseek.GetSearchResult(result.ID)
-					username, downloadId, fileSize := selectBestResponse(result.Responses)
-					// fmt.Printf("\n\n\nusername, downloadId, fileSize = %s, %s, %s\n\n\n", username, downloadId, fileSize)
-					soulseek.Transfer(username, downloadId, fileSize)
+					var err error
+					result, err = soulseek.GetSearchResult(ctx, result.ID)
+					if err != nil {
+						failDownload(ctx, track, err, queue)
+						return
+					}
+					candidates, rejected := selectCandidates(ctx, soulseek, track, result.Responses)
+					history.SetRejected(track.Query(), rejected)
+					if len(candidates) == 0 {
+						failDownload(ctx, track, fmt.Errorf("no search result matched"), queue)
+						return
+					}
+					if wantsAlbum(track) {
+						candidates = preferAlbumFolder(track, candidates)
+					}
+					history.SetCandidates(track.Query(), candidates[1:])
+					downloadCandidate(ctx, track, candidates[0], soulseek, queue)
 					return
 				}
 			}
@@ -67,17 +175,264 @@ func spawnSearchObserver(result ApiClients.SearchResult, soulseek ApiClients.Sou
 	}()
 }
 
-func selectBestResponse(responses []ApiClients.Responses) (string, string, int) {
-	sort.Slice(responses, func(i, j int) bool {
-		return responses[i].QueueLength > responses[j].QueueLength && responses[i].HasFreeUploadSlot && responses[i].FileCount > 0 && responses[i].UploadSpeed > responses[j].UploadSpeed
-	})
+// observeTransfer watches a transfer until it failed or was verified. When ctx is
+// cancelled first, the transfer stays in the history for the next start.
+func observeTransfer(ctx context.Context, track ApiClients.Track, username string, filename string, fileSize int, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) {
+	defer func() {
+		if ctx.Err() == nil {
+			history.EndTransfer(track.Query(), username, filename)
+		}
+	}()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	misses := 0
+	progressBytes, progressAt := -1, time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-	var files = responses[0].Files
-	sort.Slice(files, func(i, j int) bool {
-		return !files[i].IsLocked && strings.HasSuffix(files[i].Filename, ".mp3") && files[i].Size > files[j].Size
+		transfers, err := soulseek.GetDownloads(ctx, username)
+		if err != nil {
+			fmt.Printf("Could not poll the transfer of %s: %s\n", filename, err)
+			continue
+		}
+		transfer, found := transfers.Find(filename)
+		if !found {
+			misses++
+			if misses > 12 {
+				recordOutcome(username, OutcomeFailed)
+				downloadFailed(ctx, track, fmt.Errorf("slskd does not know about the transfer of %s", filename), soulseek, queue)
+				return
+			}
+			continue
+		}
+		misses = 0
+
+		if !strings.Contains(transfer.State, "Completed") {
+			if transfer.BytesTransferred != progressBytes {
+				progressBytes, progressAt = transfer.BytesTransferred, time.Now()
+			} else if stalledAfter > 0 && time.Since(progressAt) > stalledAfter {
+				cancelStalledTransfer(ctx, track, username, transfer, soulseek, queue)
+				return
+			}
+			continue
+		}
+		if !strings.Contains(transfer.State, "Succeeded") {
+			recordOutcome(username, OutcomeFailed)
+			downloadFailed(ctx, track, fmt.Errorf("transfer of %s ended as '%s'", filename, transfer.State), soulseek, queue)
+			return
+		}
+
+		path := localDownloadPath(filename)
+		err = verifyTrack(track, path, fileSize)
+		if err != nil {
+			recordOutcome(username, OutcomeCorrupt)
+			downloadFailed(ctx, track, err, soulseek, queue)
+			return
+		}
+
+		fmt.Printf("Downloaded '%s' to %s\n", track.Query(), path)
+		recordOutcome(username, OutcomeCompleted)
+		onDownloaded(track, path, "soulseek")
+		return
+	}
+}
+
+// cancelStalledTransfer gives up on a transfer that made no progress for
+// STALLED_TRANSFER_MINUTES, whether queued by the peer or stuck midway.
+func cancelStalledTransfer(ctx context.Context, track ApiClients.Track, username string, transfer ApiClients.TransferFile, soulseek ApiClients.Soulseek, queue chan ApiClients.Track) {
+	recordOutcome(username, OutcomeStalled)
+	err := soulseek.CancelDownload(ctx, username, transfer.ID)
+	if err != nil {
+		fmt.Printf("Could not cancel the transfer of %s: %s\n", transfer.Filename, err)
+	}
+
+	downloadFailed(ctx, track, fmt.Errorf("transfer of %s stalled as '%s' for %s", transfer.Filename, transfer.State, stalledAfter), soulseek, queue)
+}
+
+func failDownload(ctx context.Context, track ApiClients.Track, reason error, queue chan ApiClients.Track) {
+	if slskdUnavailable(reason) {
+		fmt.Printf("Could not download '%s', trying again in a minute: %s\n", track.Query(), reason)
+		retryLater(ctx, track, queue)
+		return
+	}
+	var requestErr *ApiClients.RequestError
+	if errors.As(reason, &requestErr) && requestErr.Kind == ApiClients.ErrUnauthorized {
+		fmt.Printf("slskd refuses requests, check that its web authentication is disabled for spotiseek: %s\n", reason)
+	}
+
+	previous, _ := history.Get(track.Query())
+	attempts := history.MarkFailed(track.Query(), track.Artists, reason.Error())
+	fmt.Printf("Download of '%s' failed (attempt %d): %s\n", track.Query(), attempts, reason)
+	events.Publish(Events.DownloadFailed, track.Query(), reason.Error())
+	if fallback != nil && attempts >= fallbackAfter {
+		go downloadWithFallback(ctx, track)
+		return
+	}
+	if wishlistInterval > 0 && errors.Is(reason, errNoResults) && (attempts >= maxAttempts || previous.WishlistSearches > 0) {
+		history.MarkWishlisted(track.Query())
+		fmt.Printf("Nobody shares '%s', searching again in %s\n", track.Query(), wishlistInterval)
+		events.Publish(Events.TrackWishlisted, track.Query(), "")
+		return
+	}
+	if attempts < maxAttempts {
+		sendToQueue(ctx, queue, track)
+	}
+}
+
+func downloadWithFallback(ctx context.Context, track ApiClients.Track) {
+	if problem := downloadProblem(ctx); problem != "" {
+		fmt.Printf("Not handing '%s' over to %s for now, %s\n", track.Query(), fallback.Name(), problem)
+		keepPending(track)
+		return
+	}
+	fmt.Printf("Handing '%s' over to %s\n", track.Query(), fallback.Name())
+	path, err := fallback.Download(ctx, track.Query())
+	if err == nil {
+		err = verifyTrack(track, path, 0)
+	}
+	if err != nil {
+		history.MarkFailed(track.Query(), track.Artists, err.Error())
+		fmt.Printf("%s could not download '%s': %s\n", fallback.Name(), track.Query(), err)
+		events.Publish(Events.DownloadFailed, track.Query(), fallback.Name()+": "+err.Error())
+		return
+	}
+
+	fmt.Printf("Downloaded '%s' to %s using %s\n", track.Query(), path, fallback.Name())
+	onDownloaded(track, path, fallback.Name())
+}
+
+// onDownloaded records a verified download and passes it on to the configured integrations.
+func onDownloaded(track ApiClients.Track, path string, source string) {
+	if source != "index" && source != "library" {
+		path = organize(track, path)
+	}
+	history.MarkDownloaded(track.Query(), path, source)
+	events.Publish(Events.DownloadCompleted, track.Query(), path)
+	playlistChanged.Store(true)
+	if source != "index" && source != "library" {
+		// linked files share their permissions with the original
+		applyOwnership(path)
+	}
+	if source != "index" {
+		addToIndex(track, path)
+	}
+
+	for _, integration := range integrations {
+		err := integration.Import(path)
+		if err != nil {
+			fmt.Printf("%s could not import %s: %s\n", integration.Name(), path, err)
+		}
+	}
+}
+
+// selectCandidates returns up to MAX_CANDIDATES of the highest ranked files for the
+// track, best first, leaving out those below MATCH_THRESHOLD. The best MAX_CANDIDATES
+// files below it are returned as well, to show why a track could not be matched.
+func selectCandidates(ctx context.Context, soulseek ApiClients.Soulseek, track ApiClients.Track, responses []ApiClients.Responses) ([]Candidate, []Candidate) {
+	ranked := rankResponses(track, responses, matcher)
+	if folderScoring {
+		ranked = preferCompleteFolders(ctx, soulseek, ranked)
+	}
+
+	var candidates, rejected []Candidate
+	for _, file := range ranked {
+		candidate := Candidate{file.response.Username, file.file.Filename, file.file.Size, file.score}
+		if file.score >= matchThreshold {
+			if len(candidates) < maxCandidates {
+				candidates = append(candidates, candidate)
+			}
+			continue
+		}
+		if len(rejected) >= maxCandidates {
+			break
+		}
+		rejected = append(rejected, candidate)
+	}
+
+	return candidates, rejected
+}
+
+type rankedFile struct {
+	response   ApiClients.Responses
+	file       ApiClients.File
+	score      float64
+	reputation float64
+}
+
+// rankResponses orders the unlocked MP3 and FLAC files of acceptable seeders by their
+// score for the track, which tolerates artist aliases and the naming of compilation
+// rips, and prefers users with a good reputation, free upload slots, short queues,
+// MP3s and fast peers among equal scores. Other files would fail verification.
+func rankResponses(track ApiClients.Track, responses []ApiClients.Responses, matcher Matcher.Matcher) []rankedFile {
+	reputations := readReputations()
+	scored := primaryArtist(track)
+	queries := []string{scored.Query()}
+	if aliased, ok := withAliases(scored); ok {
+		// files may name the artist either way
+		queries = append(queries, aliased.Query())
+	}
+	var ranked []rankedFile
+	for _, response := range responses {
+		if !acceptableSeeder(response) {
+			continue
+		}
+		reputation := Reputation{}.Score()
+		if known, ok := reputations[response.Username]; ok {
+			reputation = known.Score()
+		}
+		for _, file := range response.Files {
+			if file.IsLocked || !verifiableFile(file.Filename) {
+				continue
+			}
+			filenames := []string{file.Filename}
+			if track.Compilation {
+				filenames = append(filenames, compilationFilename(file.Filename))
+			}
+			score := 0.0
+			for _, query := range queries {
+				for _, filename := range filenames {
+					candidate := Matcher.Candidate{Filename: filename, Length: time.Duration(file.Length) * time.Second}
+					score = math.Max(score, matcher.Score(query, track.Duration, candidate))
+				}
+			}
+			ranked = append(ranked, rankedFile{response, file, score, reputation})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.reputation != b.reputation {
+			return a.reputation > b.reputation
+		}
+		if a.response.HasFreeUploadSlot != b.response.HasFreeUploadSlot {
+			return a.response.HasFreeUploadSlot
+		}
+		if a.response.QueueLength != b.response.QueueLength {
+			return a.response.QueueLength < b.response.QueueLength
+		}
+		if isMp3(a.file) != isMp3(b.file) {
+			return isMp3(a.file)
+		}
+		if a.response.UploadSpeed != b.response.UploadSpeed {
+			return a.response.UploadSpeed > b.response.UploadSpeed
+		}
+
+		return a.file.Size > b.file.Size
 	})
 
-	return responses[0].Username, files[0].Filename, files[0].Size
+	return ranked
+}
+
+func isMp3(file ApiClients.File) bool {
+	return strings.HasSuffix(strings.ToLower(file.Filename), ".mp3")
 }
 
 func initSignalHandling() {
@@ -95,36 +450,295 @@ func initSignalHandling() {
 	<-done
 }
 
+// checkForMissingFiles notices downloads that were deleted or moved outside of the
+// pipeline and, with REQUEUE_MISSING=1, downloads them again.
+func checkForMissingFiles(ctx context.Context, queue chan ApiClients.Track) {
+	missing := history.MarkMissingFiles()
+	if len(missing) == 0 {
+		return
+	}
+
+	playlistChanged.Store(true)
+	for _, query := range missing {
+		fmt.Printf("The file of '%s' disappeared\n", query)
+	}
+	if requeueMissing {
+		requeueTracks(ctx, queue, missing)
+	}
+}
+
+// pollInterval slows polling down to POLL_INTERVAL_IDLE for playlists that have not
+// changed for IDLE_AFTER_DAYS and returns to POLL_INTERVAL once they change again.
+func pollInterval() time.Duration {
+	if idleAfter > 0 && time.Since(lastPlaylistChange) > idleAfter {
+		return idlePollInterval
+	}
+
+	return basePollInterval
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
 var lastPlaylistCheck time.Time
+var lastPlaylistChange time.Time
+var basePollInterval time.Duration
+var idlePollInterval time.Duration
+var idleAfter time.Duration
+var history *History
+var maxAttempts int
+var maxCandidates int
+var searchLimit *TokenBucket
+var stalledAfter time.Duration
+var fallback Fallback.Downloader
+var fallbackAfter int
+var maxActiveTransfers int
+var integrations []Integrations.Integration
+var durationTolerance time.Duration
+
+// tempoTolerance is how many percent the BPM tag of a download may differ from the
+// tempo tempoSource reports, zero skips the check.
+var tempoTolerance float64
+var tempoSource ApiClients.TempoSource
+var playlistFile bool
+var playlistSnapshots bool
+var playlistChanged atomic.Bool
+
+// subsonic receives the playlist when SUBSONIC_URL is set, subsonicBehind makes the
+// next check push it again because the server lacked tracks or could not be reached.
+var subsonic *Integrations.SubsonicService
+var subsonicBehind bool
+var requeueMissing bool
+var burstThreshold int
+var burstPacing time.Duration
+var burstConfirm int
+var diskQuota int64
+var minFreeSpace uint64
+var mirrorMode bool
+var mirrorGracePeriod time.Duration
+var library *Library
+var libraryLink bool
+var matcher Matcher.Matcher
+var matchThreshold float64
+var folderScoring bool
+var folderExtras []string
+var wishlistInterval time.Duration
+var wishlistMaxSearches int
+var events = Events.NewBus()
+var playlistName string
 
 func main() {
-	trackQueue := make(chan string)
+	err := loadSecretFiles()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			os.Exit(runDoctor())
+		case "redownload":
+			os.Exit(runRedownload(os.Args[2:]))
+		case "approve-burst":
+			os.Exit(runApproveBurst())
+		case "status":
+			os.Exit(runStatus(os.Args[2:]))
+		case "skip":
+			os.Exit(runSkip(os.Args[2:]))
+		case "match-test":
+			os.Exit(runMatchTest(os.Args[2:]))
+		case "wishlist":
+			os.Exit(runWishlist(os.Args[2:]))
+		case "stats":
+			os.Exit(runStats(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "healthcheck":
+			os.Exit(runHealthcheck())
+		case "reputation":
+			os.Exit(runReputation(os.Args[2:]))
+		case "triage":
+			os.Exit(runTriage(os.Args[2:]))
+		case "query":
+			os.Exit(runQuery(os.Args[2:]))
+		case "album":
+			os.Exit(runAlbum(os.Args[2:]))
+		case "watch-artist":
+			if code := setupWatchArtist(os.Args[2:]); code != 0 {
+				os.Exit(code)
+			}
+		default:
+			fmt.Printf("Unknown command '%s', available: doctor, redownload, approve-burst, status, skip, match-test, wishlist, stats, import, healthcheck, reputation, watch-artist, triage, query, album\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
+	trackQueue := make(chan ApiClients.Track)
 	lastPlaylistCheck = time.Now()
-	// lastPlaylistCheck, _ = time.Parse(time.RFC822, "1 Jan 2006 00:00:00")
 	timestamp, _ := os.ReadFile("timestamp")
-	lastPlaylistCheck, _ = time.Parse(time.RFC822, string(timestamp))
+	lastPlaylistCheck, _ = time.Parse(time.RFC3339, strings.TrimSpace(string(timestamp)))
+	if lastPlaylistCheck.Before(watchSince) {
+		lastPlaylistCheck = watchSince
+	}
+	history = LoadHistory("history.json")
+	lastPlaylistChange = time.Now()
+	basePollInterval = time.Duration(envInt("POLL_INTERVAL", 60)) * time.Second
+	idlePollInterval = time.Duration(envInt("POLL_INTERVAL_IDLE", 3600)) * time.Second
+	idleAfter = time.Duration(envInt("IDLE_AFTER_DAYS", 3)) * 24 * time.Hour
+	maxAttempts = envInt("MAX_ATTEMPTS", 3)
+	maxCandidates = envInt("MAX_CANDIDATES", 3)
+	searchLimit = NewTokenBucket(envInt("SEARCHES_PER_MINUTE", 0), envInt("SEARCH_BURST", 5))
+	stalledAfter = time.Duration(envInt("STALLED_TRANSFER_MINUTES", 30)) * time.Minute
+	fallback = Fallback.New(os.Getenv("FALLBACK"), os.Getenv("FALLBACK_COMMAND"), filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), "fallback"))
+	fallbackAfter = envInt("FALLBACK_AFTER", maxAttempts)
+	maxActiveTransfers = envInt("MAX_ACTIVE_TRANSFERS", 10)
+	integrations = Integrations.FromEnv()
+	durationTolerance = time.Duration(envInt("DURATION_TOLERANCE", 0)) * time.Second
+	tempoTolerance = float64(envInt("TEMPO_TOLERANCE", 0))
+	playlistFile = os.Getenv("PLAYLIST_FILE") == "1"
+	playlistSnapshots = os.Getenv("PLAYLIST_SNAPSHOTS") == "1"
+	folderTemplate = os.Getenv("FOLDER_TEMPLATE")
+	if os.Getenv("SUBSONIC_URL") != "" {
+		subsonic = Integrations.NewSubsonic(os.Getenv("SUBSONIC_URL"), os.Getenv("SUBSONIC_USER"), os.Getenv("SUBSONIC_PASSWORD"))
+	}
+	playlistChanged.Store(playlistFile || subsonic != nil)
+	requeueMissing = os.Getenv("REQUEUE_MISSING") == "1"
+	burstThreshold = envInt("BURST_THRESHOLD", 50)
+	burstPacing = time.Duration(envInt("BURST_PACING", 30)) * time.Second
+	burstConfirm = envInt("BURST_CONFIRM", 200)
+	diskQuota = int64(envInt("DISK_QUOTA_MB", 0)) << 20
+	minFreeSpace = uint64(envInt("MIN_FREE_SPACE_MB", 0)) << 20
+	mirrorMode = os.Getenv("MIRROR") == "1"
+	mirrorGracePeriod = time.Duration(envInt("MIRROR_GRACE_DAYS", 7)) * 24 * time.Hour
+	matcher = Matcher.New(os.Getenv("MATCHER"))
+	matchThreshold = float64(envInt("MATCH_THRESHOLD", 0)) / 100
+	folderScoring = os.Getenv("FOLDER_SCORING") == "1"
+	loadQueryConfig()
+	loadSeederConfig()
+	loadGateConfig()
+	wishlistInterval = time.Duration(envInt("WISHLIST_INTERVAL", 0)) * time.Hour
+	wishlistMaxSearches = envInt("WISHLIST_MAX_SEARCHES", 30)
+	err = loadOwnershipConfig()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if os.Getenv("AUDIT_LOG") != "" {
+		auditLog, err := Events.AuditLog(os.Getenv("AUDIT_LOG"))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		events.Subscribe(auditLog)
+	}
+	if notifiers := Notifiers.FromEnv(); len(notifiers) > 0 {
+		types := os.Getenv("NOTIFY_EVENTS")
+		if types == "" {
+			types = "DownloadCompleted,DownloadFailed,PlaylistRenamed,BurstHeld"
+		}
+		events.Subscribe(notifyEvents(notifiers, types))
+	}
+	for _, extension := range strings.Split(os.Getenv("FOLDER_EXTRAS"), ",") {
+		if strings.TrimSpace(extension) != "" {
+			folderExtras = append(folderExtras, "."+strings.TrimPrefix(strings.ToLower(strings.TrimSpace(extension)), "."))
+		}
+	}
 
-	spotify := ApiClients.NewSpotify(os.Getenv("SPOTIFY_ID"), os.Getenv("SPOTIFY_SECRET"))
+	playlist := os.Getenv("SOURCE")
+	if playlist == "" {
+		playlist = os.Getenv("SPOTIFY_PLAYLIST_ID")
+	}
+	source, sourceId := ApiClients.DetectSource(playlist)
+	if ApiClients.IsChanging(source, sourceId) && (mirrorMode || playlistFile || playlistSnapshots || subsonic != nil) {
+		fmt.Printf("'%s' replaces its tracks over time, MIRROR, PLAYLIST_FILE, PLAYLIST_SNAPSHOTS and SUBSONIC_URL cannot be used with it\n", playlist)
+		os.Exit(1)
+	}
+	if tempoTolerance > 0 {
+		var ok bool
+		tempoSource, ok = source.(ApiClients.TempoSource)
+		if !ok {
+			fmt.Printf("'%s' does not know the tempo of its tracks, TEMPO_TOLERANCE needs a Spotify source\n", playlist)
+			os.Exit(1)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if os.Getenv("LIBRARY_DIR") != "" {
+		library = NewLibrary(os.Getenv("LIBRARY_DIR"))
+		library.Scan()
+		go func() {
+			ticker := time.NewTicker(24 * time.Hour)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-draining:
+					return
+				case <-ticker.C:
+					library.Scan()
+				}
+			}
+		}()
+		libraryLink = os.Getenv("LIBRARY_LINK") == "1"
+	}
+	playlistName = source.GetPlaylist(ctx, sourceId).Name
+	fmt.Printf("Watching playlist '%s'\n", playlistName)
 	soulseek := ApiClients.NewSoulseek(os.Getenv("SLSKD_URL"))
+	waitForSlskd(ctx, soulseek, time.Duration(envInt("SLSKD_READY_TIMEOUT", 120))*time.Second)
+
+	switch os.Getenv("DIGEST") {
+	case "daily", "weekly":
+		interval := 24 * time.Hour
+		if os.Getenv("DIGEST") == "weekly" {
+			interval *= 7
+		}
+		startDigests(ctx, Notifiers.EmailFromEnv(), interval)
+	case "":
+	default:
+		fmt.Printf("Unknown DIGEST '%s', expected daily or weekly\n", os.Getenv("DIGEST"))
+		os.Exit(1)
+	}
 
 	// initialize background job
-	go searchForQueueItems(trackQueue, soulseek)
+	go searchForQueueItems(ctx, trackQueue, soulseek)
+	resumeTransfers(ctx, soulseek, trackQueue)
 
 	// Initial playlist checkf
-	checkPlaylistContents(trackQueue, spotify, os.Getenv("SPOTIFY_PLAYLIST_ID"))
+	checkPlaylistContents(ctx, trackQueue, source, sourceId)
+
+	if os.Getenv("STATE_ADDR") != "" {
+		go serveState(ctx, os.Getenv("STATE_ADDR"), soulseek)
+	}
 
 	// Recurring playlist check
-	playlistObserverTicker := time.NewTicker(60 * time.Second)
+	playlistObserverTimer := time.NewTimer(pollInterval())
 	go func() {
 		for {
 			select {
-			case <-playlistObserverTicker.C:
+			case <-ctx.Done():
+				playlistObserverTimer.Stop()
+				return
+			case <-draining:
+				playlistObserverTimer.Stop()
+				return
+			case <-playlistObserverTimer.C:
 				// fmt.Println("Tick at", t)
-				checkPlaylistContents(trackQueue, spotify, os.Getenv("SPOTIFY_PLAYLIST_ID")) // 0ICI46XxAvf56sus9c3XbQ
+				checkPlaylistContents(ctx, trackQueue, source, sourceId) // 0ICI46XxAvf56sus9c3XbQ
+				playlistObserverTimer.Reset(pollInterval())
 			}
 		}
 	}()
 
 	// Application loop
 	initSignalHandling()
+	drain(time.Duration(envInt("DRAIN_TIMEOUT", 60)) * time.Second)
+	cancel()
 }
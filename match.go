@@ -0,0 +1,99 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Matcher"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// runMatchTest scores candidates for a query so MATCHER and MATCH_THRESHOLD can be
+// tuned without downloading anything. Given sample filenames it compares every
+// matcher on them, otherwise it runs a real search on slskd and prints the ranking.
+func runMatchTest(args []string) int {
+	flags := flag.NewFlagSet("match-test", flag.ContinueOnError)
+	url := flags.String("url", os.Getenv("SLSKD_URL"), "slskd to search on")
+	name := flags.String("matcher", os.Getenv("MATCHER"), "matcher to rank search results with")
+	threshold := flags.Int("threshold", envInt("MATCH_THRESHOLD", 0), "minimum score in percent")
+	duration := flags.Int("duration", 0, "expected track length in seconds")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	if flags.NArg() < 1 {
+		fmt.Println("Usage: match-test [flags] \"<artist> <title>\" [filename...]")
+		return 2
+	}
+
+	track := ApiClients.Track{Title: flags.Arg(0), Duration: time.Duration(*duration) * time.Second}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer writer.Flush()
+
+	if flags.NArg() > 1 {
+		fmt.Fprint(writer, "FILENAME")
+		for _, name := range Matcher.Names {
+			fmt.Fprintf(writer, "\t%s", strings.ToUpper(name))
+		}
+		fmt.Fprintln(writer)
+		for _, filename := range flags.Args()[1:] {
+			fmt.Fprint(writer, filename)
+			for _, name := range Matcher.Names {
+				score := Matcher.New(name).Score(track.Query(), track.Duration, Matcher.Candidate{Filename: filename})
+				fmt.Fprintf(writer, "\t%.0f%%", score*100)
+			}
+			fmt.Fprintln(writer)
+		}
+		return 0
+	}
+
+	loadQueryConfig()
+	loadSeederConfig()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := searchAndWait(ctx, ApiClients.NewSoulseek(*url), searchQuery(track))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	fmt.Fprintln(writer, "SCORE\tUSER\tFREE SLOT\tQUEUE\tSPEED\tLENGTH\tFILENAME")
+	for _, ranked := range rankResponses(track, result.Responses, Matcher.New(*name)) {
+		marker := ""
+		if ranked.score < float64(*threshold)/100 {
+			marker = " (below threshold)"
+		}
+		fmt.Fprintf(writer, "%.0f%%%s\t%s\t%t\t%d\t%d\t%s\t%s\n", ranked.score*100, marker, ranked.response.Username,
+			ranked.response.HasFreeUploadSlot, ranked.response.QueueLength, ranked.response.UploadSpeed,
+			time.Duration(ranked.file.Length)*time.Second, ranked.file.Filename)
+	}
+
+	return 0
+}
+
+// searchWaitTimeout bounds how long match-test waits for slskd to complete a search,
+// which it normally does on its own well before.
+const searchWaitTimeout = 2 * time.Minute
+
+// searchAndWait runs a search and returns its responses once slskd completed it.
+func searchAndWait(ctx context.Context, soulseek ApiClients.Soulseek, query string) (ApiClients.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, searchWaitTimeout)
+	defer cancel()
+
+	result, err := soulseek.Search(ctx, query)
+	for err == nil && !strings.Contains(result.State, "Completed") {
+		select {
+		case <-ctx.Done():
+			return result, fmt.Errorf("search for '%s' did not complete: %w", query, ctx.Err())
+		case <-time.After(time.Second):
+		}
+		result, err = soulseek.GetSearchResult(ctx, result.ID)
+	}
+
+	return result, err
+}
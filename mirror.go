@@ -0,0 +1,69 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Events"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// mirrorRemovals moves files of tracks that were removed from the source playlist into
+// the trash folder and empties trash older than the grace period.
+func mirrorRemovals(ctx context.Context, source ApiClients.PlaylistSource, tracklistId string) {
+	current := make(map[string]bool)
+	for _, track := range source.GetTracksSince(ctx, tracklistId, time.Time{}) {
+		current[track.Query()] = true
+	}
+	// an empty answer is far more likely an API hiccup than an emptied playlist
+	if len(current) == 0 {
+		return
+	}
+
+	trash := filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), ".trash")
+	for _, entry := range history.Downloaded() {
+		if current[entry.Query] {
+			continue
+		}
+
+		err := os.MkdirAll(trash, 0777)
+		if err != nil {
+			fmt.Printf("Could not create the trash folder: %s\n", err)
+			return
+		}
+		// files of different folders can share a name
+		trashed := freePath(filepath.Join(trash, filepath.Base(entry.Filename)))
+		err = os.Rename(entry.Filename, trashed)
+		if err != nil {
+			fmt.Printf("Could not move %s to the trash: %s\n", entry.Filename, err)
+			continue
+		}
+		// the grace period starts now, not when the file was downloaded
+		now := time.Now()
+		os.Chtimes(trashed, now, now)
+
+		fmt.Printf("'%s' was removed from the playlist, moved %s to the trash\n", entry.Query, entry.Filename)
+		history.MarkRemoved(entry.Query, trashed)
+		events.Publish(Events.TrackRemoved, entry.Query, trashed)
+		playlistChanged.Store(true)
+	}
+
+	emptyTrash(trash)
+}
+
+func emptyTrash(trash string) {
+	files, err := os.ReadDir(trash)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || time.Since(info.ModTime()) < mirrorGracePeriod {
+			continue
+		}
+		os.Remove(filepath.Join(trash, file.Name()))
+	}
+}
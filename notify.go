@@ -0,0 +1,39 @@
+package main
+
+import (
+	"Spotiseek2/internal/Events"
+	"Spotiseek2/internal/Notifiers"
+	"fmt"
+	"strings"
+)
+
+// notifyEvents returns a subscriber passing events of the comma separated types on to
+// every notifier. Delivery happens in the background so slow targets do not hold up
+// the pipeline.
+func notifyEvents(notifiers []Notifiers.Notifier, types string) func(Events.Event) {
+	wanted := make(map[Events.Type]bool)
+	for _, eventType := range strings.Split(types, ",") {
+		wanted[Events.Type(strings.TrimSpace(eventType))] = true
+	}
+
+	return func(event Events.Event) {
+		if !wanted[event.Type] {
+			return
+		}
+
+		notification := Notifiers.Notification{Title: "spotiseek: " + string(event.Type), Text: event.Track}
+		if event.Track == "" {
+			notification.Text = event.Detail
+		} else if event.Detail != "" {
+			notification.Text += "\n" + event.Detail
+		}
+		for _, notifier := range notifiers {
+			go func(notifier Notifiers.Notifier) {
+				err := notifier.Notify(notification)
+				if err != nil {
+					fmt.Printf("Could not notify %s: %s\n", notifier.Name(), err)
+				}
+			}(notifier)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// queryOverrideFile maps "<artist> <title>" queries to the text searched for instead.
+// It is written by the query command while the pipeline runs, so it is read on every search.
+const queryOverrideFile = "query-overrides.json"
+
+func readQueryOverrides() map[string]string {
+	overrides := make(map[string]string)
+	contents, err := os.ReadFile(queryOverrideFile)
+	if err != nil {
+		return overrides
+	}
+	json.Unmarshal(contents, &overrides)
+
+	return overrides
+}
+
+// queryOverride returns the search text set for query with the query command.
+func queryOverride(query string) (string, bool) {
+	override, ok := readQueryOverrides()[query]
+
+	return override, ok
+}
+
+// runQuery sets the text searched for a track, removes it again with --clear, or lists
+// all overrides when called without arguments. The track may be given as part of its
+// query when that matches exactly one track in the history.
+func runQuery(args []string) int {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	remove := flags.Bool("clear", false, "search the track with the generated query again")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	overrides := readQueryOverrides()
+
+	if flags.NArg() == 0 {
+		var queries []string
+		for query := range overrides {
+			queries = append(queries, query)
+		}
+		sort.Strings(queries)
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "TRACK\tSEARCH")
+		for _, query := range queries {
+			fmt.Fprintf(writer, "%s\t%s\n", query, overrides[query])
+		}
+		writer.Flush()
+		return 0
+	}
+	if (*remove && flags.NArg() != 1) || (!*remove && flags.NArg() != 2) {
+		fmt.Println("Usage: query [--clear] \"<artist> <title>\" [\"<search text>\"]")
+		return 2
+	}
+
+	query := flags.Arg(0)
+	if entry, err := LoadHistory("history.json").Find(query); err == nil {
+		query = entry.Query
+	}
+
+	if *remove {
+		delete(overrides, query)
+	} else {
+		overrides[query] = flags.Arg(1)
+	}
+	contents, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	err = os.WriteFile(queryOverrideFile, contents, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *remove {
+		fmt.Printf("'%s' will be searched with the generated query\n", query)
+	} else {
+		fmt.Printf("'%s' will be searched as '%s', use redownload to retry it now\n", query, flags.Arg(1))
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var downloadUid int
+var downloadGid int
+var downloadUmask int64
+
+// loadOwnershipConfig reads DOWNLOAD_UID, DOWNLOAD_GID and DOWNLOAD_UMASK. An unset
+// id keeps the owner slskd gave the file, an unset umask keeps its permissions.
+func loadOwnershipConfig() error {
+	downloadUid = envInt("DOWNLOAD_UID", -1)
+	downloadGid = envInt("DOWNLOAD_GID", -1)
+	downloadUmask = -1
+	if umask := os.Getenv("DOWNLOAD_UMASK"); umask != "" {
+		value, err := strconv.ParseInt(umask, 8, 32)
+		if err != nil {
+			return fmt.Errorf("DOWNLOAD_UMASK must be octal like 002: %w", err)
+		}
+		downloadUmask = value
+	}
+
+	return nil
+}
+
+// applyOwnership hands a downloaded file and the folders between it and
+// SLSKD_DOWNLOAD_DIR to the configured user and group, so media servers running as
+// another user can read them. The download directory itself is left alone.
+func applyOwnership(path string) {
+	targets := []string{path}
+	root := filepath.Clean(os.Getenv("SLSKD_DOWNLOAD_DIR"))
+	for folder := filepath.Dir(path); ; folder = filepath.Dir(folder) {
+		relative, err := filepath.Rel(root, folder)
+		if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			break
+		}
+		targets = append(targets, folder)
+	}
+
+	for _, target := range targets {
+		if downloadUid >= 0 || downloadGid >= 0 {
+			err := os.Chown(target, downloadUid, downloadGid)
+			if err != nil {
+				fmt.Printf("Could not change the owner of %s: %s\n", target, err)
+			}
+		}
+		if downloadUmask >= 0 {
+			mode := os.FileMode(0666)
+			if target != path {
+				mode = 0777
+			}
+			err := os.Chmod(target, mode&^os.FileMode(downloadUmask))
+			if err != nil {
+				fmt.Printf("Could not change the permissions of %s: %s\n", target, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"Spotiseek2/internal/Events"
+	"Spotiseek2/internal/Integrations"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writePlaylistFile writes an .m3u8 with every downloaded track of the playlist into
+// SLSKD_DOWNLOAD_DIR, in the order the tracks appear on the source playlist.
+func writePlaylistFile(ctx context.Context, source ApiClients.PlaylistSource, playlistId string) error {
+	playlist := source.GetPlaylist(ctx, playlistId)
+
+	return writeM3u(ctx, source, playlistId, filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), safeFilename(playlist.Name)+".m3u8"))
+}
+
+// writeSnapshot writes the playlist as it is this week into a snapshots folder, named
+// after the day the week started. Once the week is over its snapshot stays as it was,
+// which together with the downloads kept without MIRROR archives rotating playlists.
+func writeSnapshot(ctx context.Context, source ApiClients.PlaylistSource, playlistId string) error {
+	path := snapshotPath()
+	err := os.MkdirAll(filepath.Dir(path), 0777)
+	if err != nil {
+		return err
+	}
+
+	return writeM3u(ctx, source, playlistId, path)
+}
+
+// snapshotPath is the snapshot file of the current week.
+func snapshotPath() string {
+	now := time.Now()
+	week := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, now.Location())
+	name := fmt.Sprintf("%s %s.m3u8", safeFilename(playlistName), week.Format(time.DateOnly))
+
+	return filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), "snapshots", name)
+}
+
+// writeM3u lists the downloaded tracks of the playlist in its order, with paths
+// relative to the file at path.
+func writeM3u(ctx context.Context, source ApiClients.PlaylistSource, playlistId string, path string) error {
+	lines := []string{"#EXTM3U"}
+	for _, track := range source.GetTracksSince(ctx, playlistId, time.Time{}) {
+		entry, ok := history.Get(track.Query())
+		if !ok || entry.State != StateDownloaded {
+			continue
+		}
+
+		relative, err := filepath.Rel(filepath.Dir(path), entry.Filename)
+		if err != nil {
+			relative = entry.Filename
+		}
+		lines = append(lines,
+			fmt.Sprintf("#EXTINF:%d,%s - %s", int(track.Duration.Seconds()), strings.Join(track.Artists, ", "), track.Title),
+			filepath.ToSlash(relative),
+		)
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0666)
+}
+
+// pushPlaylist mirrors the downloaded tracks of the playlist into a playlist of the
+// same name on the Subsonic server. It reports whether the server still lacked some
+// of them, which happens until it scanned the new files.
+func pushPlaylist(ctx context.Context, source ApiClients.PlaylistSource, playlistId string) (bool, error) {
+	var tracks []Integrations.PlaylistTrack
+	for _, track := range source.GetTracksSince(ctx, playlistId, time.Time{}) {
+		entry, ok := history.Get(track.Query())
+		if !ok || entry.State != StateDownloaded || len(track.Artists) == 0 {
+			continue
+		}
+		tracks = append(tracks, Integrations.PlaylistTrack{Artist: track.Artists[0], Title: track.Title})
+	}
+
+	missing, err := subsonic.PushPlaylist(playlistName, tracks)
+	if err != nil {
+		return false, err
+	}
+	if missing > 0 {
+		fmt.Printf("The Subsonic server does not have %d of %d playlist tracks yet\n", missing, len(tracks))
+	}
+
+	return missing > 0, nil
+}
+
+// followPlaylistRename notices when the source playlist got a new name and moves the
+// playlist file along, so media servers do not show the old name next to the new one.
+func followPlaylistRename(ctx context.Context, source ApiClients.PlaylistSource, playlistId string) {
+	name := source.GetPlaylist(ctx, playlistId).Name
+	if playlistName == "" || name == playlistName {
+		playlistName = name
+		return
+	}
+
+	fmt.Printf("Playlist '%s' was renamed to '%s'\n", playlistName, name)
+	events.Publish(Events.PlaylistRenamed, "", playlistName+" -> "+name)
+	if playlistFile {
+		dir := os.Getenv("SLSKD_DOWNLOAD_DIR")
+		err := os.Rename(filepath.Join(dir, safeFilename(playlistName)+".m3u8"), filepath.Join(dir, safeFilename(name)+".m3u8"))
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Could not rename the playlist file: %s\n", err)
+		}
+		playlistChanged.Store(true)
+	}
+	playlistName = name
+}
+
+func safeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"github.com/mozillazg/go-unidecode"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// defaultQueryNoise are the words and phrases OPTIMIZE_QUERIES drops from titles.
+const defaultQueryNoise = "feat.,feat,ft.,ft,featuring,with,original mix,radio edit,remastered,remaster"
+
+var transliterateQueries bool
+var optimizeQueries bool
+var queryMaxWords int
+var queryNoise [][]string
+
+// loadQueryConfig reads the settings that shape search queries from the environment.
+func loadQueryConfig() {
+	transliterateQueries = os.Getenv("TRANSLITERATE_QUERIES") == "1"
+	optimizeQueries = os.Getenv("OPTIMIZE_QUERIES") == "1"
+	queryMaxWords = envInt("QUERY_MAX_WORDS", 6)
+
+	noise := os.Getenv("QUERY_NOISE_WORDS")
+	if noise == "" {
+		noise = defaultQueryNoise
+	}
+	queryNoise = parseNoise(noise)
+}
+
+// parseNoise splits a comma separated list of noise phrases into their words.
+func parseNoise(list string) [][]string {
+	var noise [][]string
+	for _, phrase := range strings.Split(list, ",") {
+		if words := queryWords(phrase); len(words) > 0 {
+			noise = append(noise, words)
+		}
+	}
+
+	return noise
+}
+
+// suffixNoise are noise words that are part of real titles too, like "Dancing with
+// Myself", so they are only dropped from the suffix of a title.
+var suffixNoise = map[string]bool{"with": true}
+
+// featuring words start the credits of guest artists in a title.
+var featuring = map[string]bool{"feat.": true, "feat": true, "ft.": true, "ft": true, "featuring": true}
+
+// searchQuery is the text sent to Soulseek for a track. History, skip list and
+// matching keep using track.Query(), so changing how searches are phrased never
+// makes already downloaded tracks look new. A query set with the query command is
+// searched as it was given, otherwise artists are searched by their aliases and
+// compilation tracks by their primary artist.
+func searchQuery(track ApiClients.Track) string {
+	if override, ok := queryOverride(track.Query()); ok {
+		return override
+	}
+
+	track, _ = withAliases(primaryArtist(track))
+	query := track.Query()
+	if optimizeQueries {
+		query = optimizeQuery(track.Artists, track.Title, queryNoise, queryMaxWords)
+	}
+	if transliterateQueries {
+		query = strings.Join(strings.Fields(unidecode.Unidecode(query)), " ")
+	}
+
+	return query
+}
+
+// optimizeQuery drops noise words from the title, leaves out artists the title
+// already names and cuts the query down to maxWords, since Soulseek only returns
+// files matching every word. Secondary artists go before the title is shortened.
+func optimizeQuery(artists []string, title string, noise [][]string, maxWords int) string {
+	titleWords := removeNoise(titleSuffixes(title), noise)
+	if len(titleWords) == 0 {
+		titleWords = queryWords(title)
+	}
+
+	var artistWords [][]string
+	for _, artist := range artists {
+		words := queryWords(artist)
+		if len(words) > 0 && !containsPhrase(titleWords, words) {
+			artistWords = append(artistWords, words)
+		}
+	}
+
+	count := func() int {
+		total := len(titleWords)
+		for _, words := range artistWords {
+			total += len(words)
+		}
+		return total
+	}
+	for maxWords > 0 && count() > maxWords && len(artistWords) > 1 {
+		artistWords = artistWords[:len(artistWords)-1]
+	}
+
+	var words []string
+	for _, artist := range artistWords {
+		words = append(words, artist...)
+	}
+	words = append(words, titleWords...)
+	if maxWords > 0 && len(words) > maxWords {
+		words = words[:maxWords]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// queryWords splits text into words without the brackets, dashes and other
+// punctuation around them.
+func queryWords(text string) []string {
+	var words []string
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return (unicode.IsPunct(r) && r != '.' && r != '\'') || unicode.IsSymbol(r)
+		})
+		word = strings.TrimLeft(word, ".'")
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
+
+// titleWord is a word of a title, suffix is set for words in brackets, after a
+// dash or after a featuring word, where versions and guest artists are credited.
+type titleWord struct {
+	text   string
+	suffix bool
+}
+
+func titleSuffixes(title string) []titleWord {
+	var words []titleWord
+	depth, suffix := 0, false
+	for _, field := range strings.Fields(title) {
+		depth += strings.Count(field, "(") + strings.Count(field, "[")
+		if field == "-" {
+			suffix = true
+		}
+		for _, word := range queryWords(field) {
+			words = append(words, titleWord{word, suffix || depth > 0})
+			if featuring[strings.ToLower(word)] {
+				suffix = true
+			}
+		}
+		depth -= strings.Count(field, ")") + strings.Count(field, "]")
+		if depth < 0 {
+			depth = 0
+		}
+	}
+
+	return words
+}
+
+func removeNoise(words []titleWord, noise [][]string) []string {
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.text
+	}
+
+	var kept []string
+	for i := 0; i < len(words); i++ {
+		skipped := false
+		for _, phrase := range noise {
+			if len(phrase) == 1 && suffixNoise[strings.ToLower(phrase[0])] && !words[i].suffix {
+				continue
+			}
+			if hasPrefixFold(texts[i:], phrase) {
+				i += len(phrase) - 1
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			kept = append(kept, words[i].text)
+		}
+	}
+
+	return kept
+}
+
+func containsPhrase(words []string, phrase []string) bool {
+	for i := range words {
+		if hasPrefixFold(words[i:], phrase) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasPrefixFold(words []string, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if !strings.EqualFold(words[i], prefix[i]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestOptimizeQuery(t *testing.T) {
+	noise := parseNoise(defaultQueryNoise)
+	tests := []struct {
+		name    string
+		artists []string
+		title   string
+		want    string
+	}{
+		{"plain title", []string{"Daft Punk"}, "One More Time", "Daft Punk One More Time"},
+		{"feat. in brackets", []string{"Calvin Harris"}, "Feels (feat. Pharrell Williams)", "Calvin Harris Feels Pharrell Williams"},
+		{"featured artist already named", []string{"Calvin Harris", "Pharrell Williams"}, "Feels (feat. Pharrell Williams)", "Calvin Harris Feels Pharrell Williams"},
+		{"with in brackets", []string{"The Kid LAROI", "Justin Bieber"}, "Stay (with Justin Bieber)", "The Kid LAROI Stay Justin Bieber"},
+		{"with after feat.", []string{"Artist"}, "Song feat. Singer with Band", "Artist Song Singer Band"},
+		{"with in the title", []string{"Billy Idol"}, "Dancing with Myself", "Billy Idol Dancing with Myself"},
+		{"title starting with with", []string{"U2"}, "With or Without You", "U2 With or Without You"},
+		{"remaster suffix", []string{"Queen"}, "Bohemian Rhapsody - Remastered 2011", "Queen Bohemian Rhapsody 2011"},
+		{"remaster in brackets", []string{"Oasis"}, "Wonderwall (Remastered)", "Oasis Wonderwall"},
+		{"radio edit suffix", []string{"Avicii"}, "Levels - Radio Edit", "Avicii Levels"},
+		{"original mix", []string{"deadmau5"}, "Strobe (Original Mix)", "deadmau5 Strobe"},
+		{"live is kept", []string{"Eagles"}, "Hotel California - Live", "Eagles Hotel California Live"},
+		{"live version in brackets", []string{"Nirvana"}, "About a Girl (Live)", "Nirvana About a Girl Live"},
+		{"only noise", []string{"Artist"}, "Remastered", "Artist Remastered"},
+		{"secondary artists dropped first", []string{"A", "Second Artist", "Third Artist"}, "Long Song Title Here", "A Long Song Title Here"},
+		{"cut to max words", []string{"Artist"}, "One Two Three Four Five Six Seven", "Artist One Two Three Four Five"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := optimizeQuery(test.artists, test.title, noise, 6)
+			if got != test.want {
+				t.Errorf("optimizeQuery(%q, %q) = %q, want %q", test.artists, test.title, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenBucket allows burst searches at once and refills at rate searches per
+// minute, so backfills do not flood the Soulseek network with searches.
+type TokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	filled time.Time
+}
+
+// NewTokenBucket returns a limiter for perMinute searches, or nil when perMinute is
+// zero and searches are not limited.
+func NewTokenBucket(perMinute int, burst int) *TokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		filled: time.Now(),
+	}
+}
+
+// Wait takes a token, waiting for one to refill when the bucket is empty. It returns
+// false when ctx was cancelled or shutdown began first. A nil bucket never waits.
+func (tb *TokenBucket) Wait(ctx context.Context) bool {
+	if tb == nil {
+		return true
+	}
+
+	for {
+		tb.mutex.Lock()
+		now := time.Now()
+		tb.tokens += now.Sub(tb.filled).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+		tb.filled = now
+		if tb.tokens >= 1 {
+			tb.tokens--
+			tb.mutex.Unlock()
+			return true
+		}
+		delay := time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+		tb.mutex.Unlock()
+
+		fmt.Printf("Search limit reached, waiting %s\n", delay.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return false
+		case <-draining:
+			return false
+		case <-time.After(delay):
+		}
+	}
+}
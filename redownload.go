@@ -0,0 +1,104 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// redownloadFile lists queries the running pipeline should search again, one per line.
+const redownloadFile = "redownload"
+
+// runRedownload moves the current file of a downloaded track aside and asks the
+// running pipeline to search for it again. The history of earlier attempts is kept.
+func runRedownload(args []string) int {
+	if len(args) != 1 {
+		fmt.Println("Usage: redownload \"<artist> <title>\"")
+		return 2
+	}
+
+	history := LoadHistory("history.json")
+	entry, err := history.Find(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if entry.Filename != "" {
+		err = os.Rename(entry.Filename, entry.Filename+".old")
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Could not move %s aside: %s\n", entry.Filename, err)
+			return 1
+		}
+	}
+
+	file, err := os.OpenFile(redownloadFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+	fmt.Fprintln(file, entry.Query)
+
+	fmt.Printf("'%s' will be searched again on the next playlist check\n", entry.Query)
+	return 0
+}
+
+// takeRedownloads returns and clears the queries requested with runRedownload.
+func takeRedownloads() []string {
+	return takeLines(redownloadFile)
+}
+
+// takeLines returns and clears the non-empty lines of a hand-off file. The file is
+// renamed before it is read, so lines appended meanwhile, by another process too,
+// land in a fresh file for the next check instead of getting lost.
+func takeLines(path string) []string {
+	pendingMutex.Lock()
+	taken := path + ".taken"
+	err := os.Rename(path, taken)
+	pendingMutex.Unlock()
+	if err != nil {
+		return nil
+	}
+	contents, err := os.ReadFile(taken)
+	if err != nil {
+		return nil
+	}
+	os.Remove(taken)
+
+	var lines []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+
+	return lines
+}
+
+// queueRedownloads puts requested tracks back into the pipeline.
+func queueRedownloads(ctx context.Context, queue chan ApiClients.Track) {
+	requeueTracks(ctx, queue, takeRedownloads())
+}
+
+// requeueTracks clears the history of the given queries and searches for them again,
+// using the playlist entry the history remembered so duration checks keep working.
+func requeueTracks(ctx context.Context, queue chan ApiClients.Track, queries []string) {
+	if len(queries) == 0 {
+		return
+	}
+
+	var requeued []ApiClients.Track
+	for _, query := range queries {
+		track := ApiClients.Track{Title: query}
+		if entry, ok := history.Get(query); ok && entry.Track != nil {
+			track = *entry.Track
+		}
+		history.Requeue(query)
+		fmt.Printf("Queueing '%s'\n", query)
+		requeued = append(requeued, track)
+	}
+	enqueueTracks(ctx, queue, requeued)
+}
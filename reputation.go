@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// reputationFile keeps how downloads from every Soulseek user went.
+const reputationFile = "reputation.json"
+
+const (
+	OutcomeCompleted = "completed"
+	OutcomeStalled   = "stalled"
+	OutcomeFailed    = "failed"
+	OutcomeCorrupt   = "corrupt"
+)
+
+type Reputation struct {
+	Username  string    `json:"username"`
+	Completed int       `json:"completed"`
+	Stalled   int       `json:"stalled"`
+	Failed    int       `json:"failed"`
+	Corrupt   int       `json:"corrupt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Score estimates how likely a download from the user completes, starting at one
+// half for unknown users and moving towards their record with every outcome.
+func (r Reputation) Score() float64 {
+	total := r.Completed + r.Stalled + r.Failed + r.Corrupt
+
+	return float64(r.Completed+1) / float64(total+2)
+}
+
+var reputationMutex sync.Mutex
+
+// loadReputations reads the reputation file, which is also changed by the
+// reputation command while the pipeline runs, so it is read on every use.
+func loadReputations() map[string]*Reputation {
+	reputations := make(map[string]*Reputation)
+	contents, err := os.ReadFile(reputationFile)
+	if err != nil {
+		return reputations
+	}
+	json.Unmarshal(contents, &reputations)
+
+	return reputations
+}
+
+// readReputations returns the current reputations for ranking search results.
+func readReputations() map[string]*Reputation {
+	reputationMutex.Lock()
+	defer reputationMutex.Unlock()
+
+	return loadReputations()
+}
+
+func saveReputations(reputations map[string]*Reputation) {
+	contents, err := json.MarshalIndent(reputations, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	os.WriteFile(reputationFile, contents, 0666)
+}
+
+// recordOutcome counts how a download from username ended.
+func recordOutcome(username string, outcome string) {
+	reputationMutex.Lock()
+	defer reputationMutex.Unlock()
+
+	reputations := loadReputations()
+	reputation, ok := reputations[username]
+	if !ok {
+		reputation = &Reputation{Username: username}
+		reputations[username] = reputation
+	}
+	switch outcome {
+	case OutcomeCompleted:
+		reputation.Completed++
+	case OutcomeStalled:
+		reputation.Stalled++
+	case OutcomeFailed:
+		reputation.Failed++
+	case OutcomeCorrupt:
+		reputation.Corrupt++
+	}
+	reputation.UpdatedAt = time.Now()
+	saveReputations(reputations)
+}
+
+// runReputation lists the users downloaded from with their record, or forgets the
+// record of the given users with --reset.
+func runReputation(args []string) int {
+	flags := flag.NewFlagSet("reputation", flag.ContinueOnError)
+	output := flags.String("output", "table", "output format: table, json or yaml")
+	reset := flags.Bool("reset", false, "forget the record of the given users, or of everyone without arguments")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+
+	reputationMutex.Lock()
+	defer reputationMutex.Unlock()
+	reputations := loadReputations()
+
+	if *reset {
+		if flags.NArg() == 0 {
+			reputations = make(map[string]*Reputation)
+		}
+		for _, username := range flags.Args() {
+			delete(reputations, username)
+		}
+		saveReputations(reputations)
+		fmt.Println("Reputations reset")
+		return 0
+	}
+
+	var list []Reputation
+	for _, reputation := range reputations {
+		list = append(list, *reputation)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Score() > list[j].Score()
+	})
+
+	err := printReport(list, *output, func(writer *tabwriter.Writer) {
+		fmt.Fprintln(writer, "USER\tSCORE\tCOMPLETED\tSTALLED\tFAILED\tCORRUPT\tUPDATED")
+		for _, reputation := range list {
+			fmt.Fprintf(writer, "%s\t%.0f%%\t%d\t%d\t%d\t%d\t%s\n", reputation.Username, reputation.Score()*100, reputation.Completed,
+				reputation.Stalled, reputation.Failed, reputation.Corrupt, reputation.UpdatedAt.Format(time.DateTime))
+		}
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	return 0
+}
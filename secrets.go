@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretVariables may be given as NAME_FILE pointing at a file with the value,
+// e.g. a Docker secret under /run/secrets, instead of a plain environment variable.
+var secretVariables = []string{
+	"SPOTIFY_ID",
+	"SPOTIFY_SECRET",
+	"TIDAL_ID",
+	"TIDAL_SECRET",
+	"LASTFM_API_KEY",
+	"LIDARR_API_KEY",
+	"PLEX_TOKEN",
+	"JELLYFIN_API_KEY",
+	"SUBSONIC_PASSWORD",
+	"SMTP_PASSWORD",
+	"GOTIFY_TOKEN",
+	"NTFY_TOKEN",
+}
+
+// loadSecretFiles copies the contents of every NAME_FILE into NAME.
+func loadSecretFiles() error {
+	for _, name := range secretVariables {
+		path := os.Getenv(name + "_FILE")
+		if path == "" {
+			continue
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		os.Setenv(name, strings.TrimSpace(string(contents)))
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"os"
+)
+
+var maxQueueLength int
+var minUploadSpeed int
+var requireFreeSlot bool
+
+// loadSeederConfig reads the limits a peer has to meet to be downloaded from.
+func loadSeederConfig() {
+	maxQueueLength = envInt("MAX_QUEUE_LENGTH", 100)
+	minUploadSpeed = envInt("MIN_UPLOAD_SPEED_KB", 0) * 1024
+	requireFreeSlot = os.Getenv("REQUIRE_FREE_SLOT") == "1"
+}
+
+// acceptableSeeder reports whether a peer is likely to deliver the file soon: its
+// queue is not too long, its upload speed is high enough and, with REQUIRE_FREE_SLOT,
+// it has an upload slot free right now. Zero limits are not checked.
+func acceptableSeeder(response ApiClients.Responses) bool {
+	if maxQueueLength > 0 && response.QueueLength > maxQueueLength {
+		return false
+	}
+	if minUploadSpeed > 0 && response.UploadSpeed < minUploadSpeed {
+		return false
+	}
+
+	return !requireFreeSlot || response.HasFreeUploadSlot
+}
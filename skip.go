@@ -0,0 +1,62 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// skipFile lists track ids or "<artist> <title>" queries that are never searched for.
+const skipFile = "skiplist"
+
+func readSkipList() []string {
+	contents, err := os.ReadFile(skipFile)
+	if err != nil {
+		return nil
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		if strings.TrimSpace(line) != "" {
+			entries = append(entries, strings.TrimSpace(line))
+		}
+	}
+
+	return entries
+}
+
+func isSkipped(track ApiClients.Track) bool {
+	for _, entry := range readSkipList() {
+		if (track.ID != "" && entry == track.ID) || strings.EqualFold(entry, track.Query()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runSkip adds a track to the skip list, or prints the list when called without arguments.
+func runSkip(args []string) int {
+	if len(args) == 0 {
+		for _, entry := range readSkipList() {
+			fmt.Println(entry)
+		}
+		return 0
+	}
+	if len(args) != 1 {
+		fmt.Println("Usage: skip <track id | \"<artist> <title>\">")
+		return 2
+	}
+
+	file, err := os.OpenFile(skipFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+	fmt.Fprintln(file, args[0])
+
+	fmt.Printf("'%s' will not be searched for\n", args[0])
+	return 0
+}
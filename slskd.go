@@ -0,0 +1,110 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reconnectInterval is how often a Soulseek reconnect is requested while slskd is
+// disconnected or keeps failing.
+const reconnectInterval = time.Minute
+
+var lastReconnect time.Time
+
+// slskdProblem pauses searching while slskd keeps failing requests or is not
+// connected to Soulseek, and asks it to reconnect now and then in the meantime.
+func slskdProblem(ctx context.Context, soulseek ApiClients.Soulseek) string {
+	if soulseek.Failing() {
+		return reconnect(ctx, soulseek, "slskd keeps failing requests")
+	}
+
+	state, err := soulseek.GetServerState(ctx)
+	if err != nil {
+		return fmt.Sprintf("could not read the server state: %s", err)
+	}
+	if state.IsConnected {
+		return ""
+	}
+	if state.IsTransitioning {
+		return fmt.Sprintf("slskd is %s", state.State)
+	}
+
+	return reconnect(ctx, soulseek, "slskd is disconnected from Soulseek")
+}
+
+// reconnect requests a Soulseek reconnect unless one was requested recently and
+// returns the reason searching stays paused, or nothing when slskd accepted it.
+func reconnect(ctx context.Context, soulseek ApiClients.Soulseek, reason string) string {
+	if time.Since(lastReconnect) < reconnectInterval {
+		return reason
+	}
+
+	lastReconnect = time.Now()
+	fmt.Printf("%s, reconnecting\n", reason)
+	err := soulseek.Connect(ctx)
+	if err != nil {
+		return fmt.Sprintf("%s, reconnecting failed: %s", reason, err)
+	}
+
+	return ""
+}
+
+// waitForSlskd polls the server state until slskd is connected and logged in to
+// Soulseek, printing its progress. It gives up after timeout and returns false,
+// searches then wait for the connection in slskdProblem instead.
+func waitForSlskd(ctx context.Context, soulseek ApiClients.Soulseek, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	previous := ""
+	for {
+		state, err := soulseek.GetServerState(ctx)
+		if err == nil && state.IsConnected && state.IsLoggedIn {
+			fmt.Printf("slskd is connected to %s\n", state.Address)
+			return true
+		}
+
+		progress := state.State
+		if err != nil {
+			progress = err.Error()
+		}
+		if progress != previous {
+			fmt.Printf("Waiting for slskd to connect: %s\n", progress)
+			previous = progress
+		}
+		if time.Now().After(deadline) {
+			fmt.Printf("slskd did not connect within %s\n", timeout)
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// slskdUnavailable reports whether a download failed because slskd could not serve
+// it right now rather than because of the track, such failures are retried later
+// without counting as an attempt.
+func slskdUnavailable(err error) bool {
+	var requestErr *ApiClients.RequestError
+	if !errors.As(err, &requestErr) {
+		return false
+	}
+
+	return requestErr.Kind == ApiClients.ErrServerOffline || requestErr.Kind == ApiClients.ErrRateLimited
+}
+
+// retryLater puts a track back on the queue once slskd had a minute to recover.
+func retryLater(ctx context.Context, track ApiClients.Track, queue chan ApiClients.Track) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Minute):
+	}
+
+	sendToQueue(ctx, queue, track)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerState is what the state endpoint reports about the running pipeline.
+type WorkerState struct {
+	Playlist        string    `json:"playlist"`
+	LastPoll        time.Time `json:"lastPoll"`
+	TracksProcessed int64     `json:"tracksProcessed"`
+	QueueDepth      int64     `json:"queueDepth"`
+	SlskdConnected  bool      `json:"slskdConnected"`
+	SlskdState      string    `json:"slskdState"`
+	PausedBy        string    `json:"pausedBy,omitempty"`
+	Healthy         bool      `json:"healthy"`
+}
+
+// lastPoll and pollDeadline hold Unix nanoseconds, they are written by the polling
+// loop and read by the state endpoint.
+var lastPoll atomic.Int64
+var pollDeadline atomic.Int64
+var tracksProcessed atomic.Int64
+var queueDepth atomic.Int64
+
+// recordPoll notes a finished playlist check. The pipeline counts as stuck when the
+// next one is more than a poll interval late.
+func recordPoll() {
+	lastPoll.Store(time.Now().UnixNano())
+	pollDeadline.Store(time.Now().Add(2*pollInterval() + time.Minute).UnixNano())
+}
+
+// sendToQueue hands a track to the search loop and counts it as waiting until then.
+// It reports false when ctx was cancelled or shutdown began first, the track is then
+// kept for the next start.
+func sendToQueue(ctx context.Context, queue chan ApiClients.Track, track ApiClients.Track) bool {
+	queueDepth.Add(1)
+	defer queueDepth.Add(-1)
+
+	select {
+	case <-ctx.Done():
+	case <-draining:
+	case queue <- track:
+		return true
+	}
+
+	keepPending(track)
+	return false
+}
+
+// serveState answers /state with the WorkerState as JSON and /health with 200 while
+// the playlist is polled on time, or 503 once polling got stuck.
+func serveState(ctx context.Context, addr string, soulseek ApiClients.Soulseek) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", func(writer http.ResponseWriter, request *http.Request) {
+		state := WorkerState{
+			Playlist:        playlistName,
+			LastPoll:        time.Unix(0, lastPoll.Load()),
+			TracksProcessed: tracksProcessed.Load(),
+			QueueDepth:      queueDepth.Load(),
+			Healthy:         pollingOnTime(),
+		}
+		state.PausedBy, _ = pausedBy.Load().(string)
+		server, err := soulseek.GetServerState(request.Context())
+		state.SlskdConnected = err == nil && server.IsConnected && server.IsLoggedIn
+		state.SlskdState = server.State
+		if err != nil {
+			state.SlskdState = err.Error()
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(state)
+	})
+	mux.HandleFunc("/health", func(writer http.ResponseWriter, request *http.Request) {
+		if !pollingOnTime() {
+			http.Error(writer, "the playlist has not been checked since "+time.Unix(0, lastPoll.Load()).Format(time.DateTime), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(writer, "ok")
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		fmt.Printf("Could not serve the state endpoint: %s\n", err)
+	}
+}
+
+func pollingOnTime() bool {
+	return time.Now().UnixNano() < pollDeadline.Load()
+}
+
+// runHealthcheck asks the state endpoint of a running pipeline whether it is healthy,
+// for use as a container HEALTHCHECK. It returns the process exit code.
+func runHealthcheck() int {
+	if os.Getenv("STATE_ADDR") == "" {
+		fmt.Println("STATE_ADDR is not set, the pipeline serves no health endpoint")
+		return 1
+	}
+
+	response, err := getState("/health")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+	fmt.Print(string(body))
+	if response.StatusCode != http.StatusOK {
+		return 1
+	}
+
+	return 0
+}
+
+// getState requests an endpoint of the running pipeline at STATE_ADDR.
+func getState(endpoint string) (*http.Response, error) {
+	addr := os.Getenv("STATE_ADDR")
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+
+	client := http.Client{Timeout: 5 * time.Second}
+	return client.Get("http://" + addr + endpoint)
+}
+
+// fetchWorkerState reads /state from the running pipeline.
+func fetchWorkerState() (WorkerState, error) {
+	var state WorkerState
+	response, err := getState("/state")
+	if err != nil {
+		return state, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return state, fmt.Errorf("/state answered HTTP %s", response.Status)
+	}
+	err = json.NewDecoder(response.Body).Decode(&state)
+
+	return state, err
+}
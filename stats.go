@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type StatsReport struct {
+	Downloaded     int            `json:"downloaded"`
+	Failed         int            `json:"failed"`
+	SuccessRate    float64        `json:"successRate"`
+	AverageScore   float64        `json:"averageScore"`
+	TotalBytes     int64          `json:"totalBytes"`
+	Sources        map[string]int `json:"sources"`
+	DownloadsByDay []DayCount     `json:"downloadsByDay"`
+	FailingArtists []ArtistCount  `json:"failingArtists"`
+}
+
+type DayCount struct {
+	Day       string `json:"day"`
+	Downloads int    `json:"downloads"`
+}
+
+type ArtistCount struct {
+	Artist   string `json:"artist"`
+	Failures int    `json:"failures"`
+}
+
+// runStats aggregates the download history of this playlist into statistics.
+func runStats(args []string) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	output := flags.String("output", "table", "output format: table, json or yaml")
+	days := flags.Int("days", 14, "number of days to count downloads for")
+	top := flags.Int("top", 10, "number of failing artists to list")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+
+	report := buildStats(LoadHistory("history.json"), *days, *top)
+	err := printReport(report, *output, func(writer *tabwriter.Writer) {
+		fmt.Fprintf(writer, "Downloaded\t%d\n", report.Downloaded)
+		fmt.Fprintf(writer, "Failed\t%d\n", report.Failed)
+		fmt.Fprintf(writer, "Success rate\t%.0f%%\n", report.SuccessRate*100)
+		fmt.Fprintf(writer, "Average match score\t%.0f%%\n", report.AverageScore*100)
+		fmt.Fprintf(writer, "Total size\t%d MiB\n", report.TotalBytes>>20)
+		var sources []string
+		for source := range report.Sources {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			fmt.Fprintf(writer, "From %s\t%d\n", source, report.Sources[source])
+		}
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "DAY\tDOWNLOADS")
+		for _, day := range report.DownloadsByDay {
+			fmt.Fprintf(writer, "%s\t%d\n", day.Day, day.Downloads)
+		}
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "ARTIST\tFAILURES")
+		for _, artist := range report.FailingArtists {
+			fmt.Fprintf(writer, "%s\t%d\n", artist.Artist, artist.Failures)
+		}
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	return 0
+}
+
+// buildStats counts downloads per day for the last days days and lists the top
+// artists with the most tracks that could not be downloaded.
+func buildStats(history *History, days int, top int) StatsReport {
+	report := StatsReport{Sources: make(map[string]int)}
+	perDay := make(map[string]int)
+	failures := make(map[string]int)
+	scored := 0
+
+	for _, entry := range history.Entries {
+		switch entry.State {
+		case StateDownloaded:
+			report.Downloaded++
+			report.Sources[entry.Source]++
+			perDay[entry.UpdatedAt.Format(time.DateOnly)]++
+			if entry.Score > 0 {
+				report.AverageScore += entry.Score
+				scored++
+			}
+			if info, err := os.Stat(entry.Filename); err == nil {
+				report.TotalBytes += info.Size()
+			}
+		case StateFailed, StateWishlisted:
+			report.Failed++
+			for _, artist := range entry.Artists {
+				failures[artist]++
+			}
+		}
+	}
+
+	if report.Downloaded+report.Failed > 0 {
+		report.SuccessRate = float64(report.Downloaded) / float64(report.Downloaded+report.Failed)
+	}
+	if scored > 0 {
+		report.AverageScore /= float64(scored)
+	}
+
+	for i := days - 1; i >= 0; i-- {
+		day := time.Now().AddDate(0, 0, -i).Format(time.DateOnly)
+		report.DownloadsByDay = append(report.DownloadsByDay, DayCount{day, perDay[day]})
+	}
+
+	for artist, count := range failures {
+		report.FailingArtists = append(report.FailingArtists, ArtistCount{artist, count})
+	}
+	sort.Slice(report.FailingArtists, func(i, j int) bool {
+		a, b := report.FailingArtists[i], report.FailingArtists[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.Artist < b.Artist
+	})
+	if len(report.FailingArtists) > top {
+		report.FailingArtists = report.FailingArtists[:top]
+	}
+
+	return report
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type StatusReport struct {
+	States    map[string]int   `json:"states"`
+	Tracks    []HistoryEntry   `json:"tracks"`
+	Transfers *TransfersStatus `json:"transfers,omitempty"`
+	PausedBy  string           `json:"pausedBy,omitempty"`
+}
+
+// TransfersStatus describes the downloads slskd has not completed yet. Speed is the
+// sum of the average speeds of the running ones in bytes per second.
+type TransfersStatus struct {
+	Active    int              `json:"active"`
+	Queued    int              `json:"queued"`
+	Speed     float64          `json:"speed"`
+	Transfers []TransferStatus `json:"list"`
+}
+
+// TransferStatus is one download, ETA counts the seconds left and is zero when unknown.
+type TransferStatus struct {
+	Username string  `json:"username"`
+	Filename string  `json:"filename"`
+	State    string  `json:"state"`
+	Percent  float64 `json:"percent"`
+	Speed    float64 `json:"speed"`
+	ETA      int     `json:"eta"`
+}
+
+// runStatus prints the download history as a table or in a machine-readable format,
+// together with the running transfers when slskd can be reached and, when the
+// pipeline serves STATE_ADDR, why it holds back searches and downloads.
+func runStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	output := flags.String("output", "table", "output format: table, json or yaml")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+
+	report := StatusReport{States: make(map[string]int)}
+	for _, entry := range LoadHistory("history.json").Entries {
+		report.States[entry.State]++
+		report.Tracks = append(report.Tracks, *entry)
+	}
+	sort.Slice(report.Tracks, func(i, j int) bool {
+		return report.Tracks[i].UpdatedAt.After(report.Tracks[j].UpdatedAt)
+	})
+
+	if os.Getenv("SLSKD_URL") != "" {
+		transfers, err := transfersStatus(ApiClients.NewSoulseek(os.Getenv("SLSKD_URL")))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read the transfers from slskd: %s\n", err)
+		}
+		report.Transfers = transfers
+	}
+
+	if os.Getenv("STATE_ADDR") != "" {
+		state, err := fetchWorkerState()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not read the state of the pipeline: %s\n", err)
+		}
+		report.PausedBy = state.PausedBy
+	}
+
+	err := printReport(report, *output, func(writer *tabwriter.Writer) {
+		if report.PausedBy != "" {
+			fmt.Fprintf(writer, "The pipeline is paused, %s\n\n", report.PausedBy)
+		}
+		if report.Transfers != nil {
+			fmt.Fprintf(writer, "%d active and %d queued transfers at %.1f KiB/s\n", report.Transfers.Active, report.Transfers.Queued, report.Transfers.Speed/1024)
+			fmt.Fprintln(writer, "TRANSFER\tUSER\tSTATE\tDONE\tSPEED\tETA")
+			for _, transfer := range report.Transfers.Transfers {
+				eta := "-"
+				if transfer.ETA > 0 {
+					eta = (time.Duration(transfer.ETA) * time.Second).String()
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%.0f%%\t%.1f KiB/s\t%s\n", transfer.Filename, transfer.Username, transfer.State,
+					transfer.Percent, transfer.Speed/1024, eta)
+			}
+			fmt.Fprintln(writer)
+		}
+		fmt.Fprintln(writer, "TRACK\tSTATE\tATTEMPTS\tSOURCE\tUPDATED")
+		for _, track := range report.Tracks {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\n", track.Query, track.State, track.Attempts, track.Source, track.UpdatedAt.Format(time.DateTime))
+		}
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	return 0
+}
+
+// transfersStatus lists the downloads slskd has not completed yet with the time
+// left at their current speed.
+func transfersStatus(soulseek ApiClients.Soulseek) (*TransfersStatus, error) {
+	users, err := soulseek.GetAllDownloads(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TransfersStatus{}
+	for _, user := range users {
+		for _, directory := range user.Directories {
+			for _, file := range directory.Files {
+				if strings.Contains(file.State, "Completed") {
+					continue
+				}
+				transfer := TransferStatus{
+					Username: user.Username,
+					Filename: path.Base(strings.ReplaceAll(file.Filename, "\\", "/")),
+					State:    file.State,
+					Percent:  file.PercentComplete,
+				}
+				if strings.Contains(file.State, "InProgress") {
+					status.Active++
+					transfer.Speed = file.AverageSpeed
+					status.Speed += file.AverageSpeed
+					if file.AverageSpeed > 0 {
+						transfer.ETA = int(float64(file.Size-file.BytesTransferred) / file.AverageSpeed)
+					}
+				} else {
+					status.Queued++
+				}
+				status.Transfers = append(status.Transfers, transfer)
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// printReport writes report as JSON or YAML with identical keys, or as a table using printTable.
+func printReport(report any, output string, printTable func(writer *tabwriter.Writer)) error {
+	switch output {
+	case "table":
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		printTable(writer)
+		return writer.Flush()
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "yaml":
+		// go through JSON so both formats share the json tag names
+		contents, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		var generic any
+		err = json.Unmarshal(contents, &generic)
+		if err != nil {
+			return err
+		}
+		return yaml.NewEncoder(os.Stdout).Encode(generic)
+	}
+
+	return fmt.Errorf("unknown output format '%s', expected table, json or yaml", output)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// fileTags are the tag fields spotiseek reads, empty when the file has none.
+type fileTags struct {
+	artist string
+	title  string
+	bpm    string
+}
+
+// readTags returns the artist and title stored in an MP3's ID3v2 tag or a FLAC's
+// Vorbis comments, or empty strings when the file has neither.
+func readTags(path string) (string, string) {
+	tags := readFileTags(path)
+
+	return tags.artist, tags.title
+}
+
+// readBpm returns the tempo DJ software stores in the tags, or zero when the file
+// has none.
+func readBpm(path string) float64 {
+	bpm, err := strconv.ParseFloat(strings.TrimSpace(readFileTags(path).bpm), 64)
+	if err != nil {
+		return 0
+	}
+
+	return bpm
+}
+
+func readFileTags(path string) fileTags {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileTags{}
+	}
+	defer file.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return fileTags{}
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return readId3(file, header)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return readVorbisComments(file)
+	}
+
+	return fileTags{}
+}
+
+func readId3(file *os.File, header []byte) fileTags {
+	version := header[3]
+	size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+	if version < 3 || size > 1<<20 {
+		return fileTags{}
+	}
+
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(file, tag); err != nil {
+		return fileTags{}
+	}
+
+	var tags fileTags
+	for offset := 0; offset+10 <= len(tag) && tag[offset] != 0; {
+		id := string(tag[offset : offset+4])
+		frameSize := int(binary.BigEndian.Uint32(tag[offset+4 : offset+8]))
+		if version == 4 {
+			frameSize = int(tag[offset+4])<<21 | int(tag[offset+5])<<14 | int(tag[offset+6])<<7 | int(tag[offset+7])
+		}
+		body := offset + 10
+		if frameSize <= 0 || body+frameSize > len(tag) {
+			break
+		}
+
+		switch id {
+		case "TPE1":
+			tags.artist = decodeId3Text(tag[body : body+frameSize])
+		case "TIT2":
+			tags.title = decodeId3Text(tag[body : body+frameSize])
+		case "TBPM":
+			tags.bpm = decodeId3Text(tag[body : body+frameSize])
+		}
+		offset = body + frameSize
+	}
+
+	return tags
+}
+
+func decodeId3Text(frame []byte) string {
+	if len(frame) < 2 {
+		return ""
+	}
+
+	text := frame[1:]
+	switch frame[0] {
+	case 0:
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+		}
+		return strings.TrimRight(string(runes), "\x00")
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+		if frame[0] == 1 && len(text) >= 2 {
+			if text[0] == 0xFF && text[1] == 0xFE {
+				order = binary.LittleEndian
+			}
+			text = text[2:]
+		}
+		units := make([]uint16, 0, len(text)/2)
+		for i := 0; i+1 < len(text); i += 2 {
+			units = append(units, order.Uint16(text[i:]))
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	}
+
+	return strings.TrimRight(string(text), "\x00")
+}
+
+func readVorbisComments(file *os.File) fileTags {
+	offset := int64(4)
+	blockHeader := make([]byte, 4)
+	for {
+		if _, err := file.ReadAt(blockHeader, offset); err != nil {
+			return fileTags{}
+		}
+		last := blockHeader[0]&0x80 != 0
+		length := int64(blockHeader[1])<<16 | int64(blockHeader[2])<<8 | int64(blockHeader[3])
+
+		if blockHeader[0]&0x7F == 4 && length < 1<<20 {
+			block := make([]byte, length)
+			if _, err := file.ReadAt(block, offset+4); err != nil {
+				return fileTags{}
+			}
+			return parseVorbisComments(block)
+		}
+		if last {
+			return fileTags{}
+		}
+		offset += 4 + length
+	}
+}
+
+func parseVorbisComments(block []byte) fileTags {
+	var tags fileTags
+
+	read := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+		length := int(binary.LittleEndian.Uint32(block))
+		if 4+length > len(block) {
+			return "", false
+		}
+		value := string(block[4 : 4+length])
+		block = block[4+length:]
+		return value, true
+	}
+
+	if _, ok := read(); !ok {
+		return fileTags{}
+	}
+	if len(block) < 4 {
+		return fileTags{}
+	}
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+
+	for i := 0; i < count; i++ {
+		comment, ok := read()
+		if !ok {
+			break
+		}
+		key, value, _ := strings.Cut(comment, "=")
+		switch strings.ToUpper(key) {
+		case "ARTIST":
+			tags.artist = value
+		case "TITLE":
+			tags.title = value
+		case "BPM":
+			tags.bpm = value
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runTriage lists the tracks that need attention because they ran out of attempts
+// or wait on the wishlist, with why the last attempt failed, the files tried, the
+// best files rejected for scoring below MATCH_THRESHOLD and how to act on them.
+func runTriage(args []string) int {
+	flags := flag.NewFlagSet("triage", flag.ContinueOnError)
+	output := flags.String("output", "table", "output format: table, json or yaml")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+
+	exhausted := envInt("MAX_ATTEMPTS", 3)
+	var tracks []HistoryEntry
+	for _, entry := range LoadHistory("history.json").Entries {
+		if (entry.State == StateFailed && entry.Attempts >= exhausted) || entry.State == StateWishlisted {
+			tracks = append(tracks, *entry)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].UpdatedAt.After(tracks[j].UpdatedAt)
+	})
+
+	err := printReport(tracks, *output, func(writer *tabwriter.Writer) {
+		fmt.Fprintln(writer, "TRACK\tSTATE\tATTEMPTS\tREASON\tTRIED\tREJECTED\tUPDATED")
+		for _, track := range tracks {
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", track.Query, track.State, track.Attempts, track.Reason,
+				describeCandidates(track.Tried), describeCandidates(track.Rejected), track.UpdatedAt.Format(time.DateTime))
+		}
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "Retry with: redownload \"<track>\"")
+		fmt.Fprintln(writer, "Search by hand with: match-test \"<track>\"")
+		fmt.Fprintln(writer, "Change what is searched with: query \"<track>\" \"<search text>\"")
+		fmt.Fprintln(writer, "Give up with: skip \"<track>\"")
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	return 0
+}
+
+// describeCandidates lists the file names of candidates with their scores.
+func describeCandidates(candidates []Candidate) string {
+	var described []string
+	for _, candidate := range candidates {
+		name := candidate.Filename[strings.LastIndexAny(candidate.Filename, `\/`)+1:]
+		described = append(described, fmt.Sprintf("%s (%.0f%%)", name, candidate.Score*100))
+	}
+
+	return strings.Join(described, ", ")
+}
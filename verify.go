@@ -0,0 +1,244 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localDownloadPath maps a remote Soulseek filename to the place slskd stores it,
+// which is the remote parent directory name and file name under SLSKD_DOWNLOAD_DIR.
+func localDownloadPath(remoteFilename string) string {
+	parts := strings.Split(strings.ReplaceAll(remoteFilename, "\\", "/"), "/")
+	if len(parts) < 2 {
+		return filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), parts[0])
+	}
+
+	return filepath.Join(os.Getenv("SLSKD_DOWNLOAD_DIR"), parts[len(parts)-2], parts[len(parts)-1])
+}
+
+// verifyTrack checks a downloaded file and, when DURATION_TOLERANCE is set, that its
+// decoded length is close to the length the playlist source reported for the track.
+// With TEMPO_TOLERANCE set its BPM tag has to match the tempo of the track as well.
+func verifyTrack(track ApiClients.Track, path string, expectedSize int) error {
+	duration, err := verifyDownload(path, expectedSize)
+	if err != nil {
+		return err
+	}
+	err = verifyTempo(track, path)
+	if err != nil {
+		return err
+	}
+
+	if durationTolerance <= 0 || track.Duration == 0 || duration == 0 {
+		return nil
+	}
+	difference := duration - track.Duration
+	if difference < 0 {
+		difference = -difference
+	}
+	if difference > durationTolerance {
+		return fmt.Errorf("%s is %s long, expected %s", path, duration.Round(time.Second), track.Duration.Round(time.Second))
+	}
+
+	return nil
+}
+
+// verifyTempo compares the BPM tag of a download with the tempo the source reports,
+// catching edits and remixes of the same length. Files without a BPM tag pass, there
+// is no beat detection for them, and so do half and double time readings of a tag.
+func verifyTempo(track ApiClients.Track, path string) error {
+	if tempoTolerance <= 0 || tempoSource == nil || track.ID == "" {
+		return nil
+	}
+	bpm := readBpm(path)
+	if bpm == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tempo, err := tempoSource.GetTempo(ctx, track.ID)
+	if err != nil {
+		fmt.Printf("Could not check the tempo of '%s': %s\n", track.Query(), err)
+		return nil
+	}
+
+	for _, expected := range []float64{tempo, tempo * 2, tempo / 2} {
+		if math.Abs(bpm-expected) <= expected*tempoTolerance/100 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is tagged with %.0f BPM, expected %.0f", path, bpm, tempo)
+}
+
+// verifiableFile reports whether verifyDownload can accept the file, other files
+// such as cover images, cue sheets or M4A are never worth downloading as a track.
+func verifiableFile(filename string) bool {
+	extension := strings.ToLower(filepath.Ext(strings.ReplaceAll(filename, "\\", "/")))
+
+	return extension == ".mp3" || extension == ".flac"
+}
+
+// verifyDownload checks that a finished transfer left a usable file behind: it has to
+// exist, have the size slskd announced and start with an MP3 or FLAC stream.
+// It returns the decoded duration, or zero when it cannot be determined.
+func verifyDownload(path string, expectedSize int) (time.Duration, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() == 0 {
+		return 0, fmt.Errorf("%s is empty", path)
+	}
+	if expectedSize > 0 && info.Size() != int64(expectedSize) {
+		return 0, fmt.Errorf("%s has %d bytes, expected %d", path, info.Size(), expectedSize)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var offset int64
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		// ID3v2 size is a 28-bit syncsafe integer, optionally followed by a footer
+		offset = 10 + (int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9]))
+		if header[5]&0x10 != 0 {
+			offset += 10
+		}
+	}
+
+	// tolerate padding between the tag and the first frame
+	audio := make([]byte, 4096)
+	n, err := file.ReadAt(audio, offset)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	audio = audio[:n]
+
+	if bytes.HasPrefix(audio, []byte("fLaC")) {
+		return flacDuration(audio), nil
+	}
+	for i := 0; i+1 < len(audio); i++ {
+		if isMp3FrameHeader(audio[i:]) {
+			return mp3Duration(audio[i:], info.Size()-offset-int64(i)), nil
+		}
+		if audio[i] != 0 {
+			break
+		}
+	}
+
+	return 0, fmt.Errorf("%s does not contain MP3 or FLAC audio", path)
+}
+
+func isMp3FrameHeader(b []byte) bool {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return false
+	}
+
+	version := (b[1] >> 3) & 0x03
+	layer := (b[1] >> 1) & 0x03
+	bitrate := b[2] >> 4
+	sampleRate := (b[2] >> 2) & 0x03
+
+	return version != 0x01 && layer != 0x00 && bitrate != 0x0F && sampleRate != 0x03
+}
+
+// flacDuration reads the total sample count and sample rate from STREAMINFO,
+// which is always the first metadata block.
+func flacDuration(audio []byte) time.Duration {
+	if len(audio) < 8+18 {
+		return 0
+	}
+
+	info := audio[8:]
+	sampleRate := int64(info[10])<<12 | int64(info[11])<<4 | int64(info[12])>>4
+	samples := int64(info[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(info[14:18]))
+	if sampleRate == 0 {
+		return 0
+	}
+
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate)
+}
+
+var mp3Bitrates = map[string][15]int64{
+	"1-1": {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	"1-2": {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	"1-3": {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	"2-1": {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	"2-2": {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	"2-3": {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+var mp3SampleRates = map[byte][3]int64{
+	0x03: {44100, 48000, 32000},
+	0x02: {22050, 24000, 16000},
+	0x00: {11025, 12000, 8000},
+}
+
+// mp3Duration uses the frame count of a Xing/Info or VBRI header when present
+// and otherwise assumes a constant bitrate stream of the given size.
+func mp3Duration(frame []byte, streamSize int64) time.Duration {
+	version := (frame[1] >> 3) & 0x03
+	layer := 4 - (frame[1]>>1)&0x03
+	mono := frame[3]>>6 == 0x03
+
+	versionKey := "2"
+	if version == 0x03 {
+		versionKey = "1"
+	}
+	bitrate := mp3Bitrates[fmt.Sprintf("%s-%d", versionKey, layer)][frame[2]>>4] * 1000
+	sampleRate := mp3SampleRates[version][(frame[2]>>2)&0x03]
+
+	samplesPerFrame := int64(1152)
+	if layer == 1 {
+		samplesPerFrame = 384
+	} else if layer == 3 && version != 0x03 {
+		samplesPerFrame = 576
+	}
+
+	sideInfo := 32
+	switch {
+	case version == 0x03 && mono:
+		sideInfo = 17
+	case version != 0x03 && !mono:
+		sideInfo = 17
+	case version != 0x03 && mono:
+		sideInfo = 9
+	}
+
+	var frames int64
+	xing := 4 + sideInfo
+	if len(frame) >= xing+12 && (bytes.Equal(frame[xing:xing+4], []byte("Xing")) || bytes.Equal(frame[xing:xing+4], []byte("Info"))) {
+		if binary.BigEndian.Uint32(frame[xing+4:xing+8])&0x01 != 0 {
+			frames = int64(binary.BigEndian.Uint32(frame[xing+8 : xing+12]))
+		}
+	} else if len(frame) >= 36+18 && bytes.Equal(frame[36:40], []byte("VBRI")) {
+		frames = int64(binary.BigEndian.Uint32(frame[50:54]))
+	}
+
+	if frames > 0 && sampleRate > 0 {
+		return time.Duration(frames*samplesPerFrame) * time.Second / time.Duration(sampleRate)
+	}
+	if bitrate > 0 {
+		return time.Duration(streamSize*8) * time.Second / time.Duration(bitrate)
+	}
+
+	return 0
+}
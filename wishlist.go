@@ -0,0 +1,58 @@
+package main
+
+import (
+	"Spotiseek2/internal/ApiClients"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// errNoResults is the failure that puts a track on the wishlist with WISHLIST_INTERVAL set.
+var errNoResults = errors.New("no search results")
+
+// queueWishlist searches again for wishlisted tracks once WISHLIST_INTERVAL has passed.
+func queueWishlist(ctx context.Context, queue chan ApiClients.Track) {
+	if wishlistInterval <= 0 {
+		return
+	}
+
+	requeueTracks(ctx, queue, history.DueWishlist(wishlistInterval, wishlistMaxSearches))
+}
+
+// runWishlist lists the tracks waiting for somebody to share them.
+func runWishlist(args []string) int {
+	flags := flag.NewFlagSet("wishlist", flag.ContinueOnError)
+	output := flags.String("output", "table", "output format: table, json or yaml")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+
+	var wishlist []HistoryEntry
+	for _, entry := range LoadHistory("history.json").Entries {
+		if entry.State == StateWishlisted {
+			wishlist = append(wishlist, *entry)
+		}
+	}
+	sort.Slice(wishlist, func(i, j int) bool {
+		return wishlist[i].UpdatedAt.Before(wishlist[j].UpdatedAt)
+	})
+
+	interval := time.Duration(envInt("WISHLIST_INTERVAL", 0)) * time.Hour
+	err := printReport(wishlist, *output, func(writer *tabwriter.Writer) {
+		fmt.Fprintln(writer, "TRACK\tSEARCHES\tLAST SEARCH\tNEXT SEARCH")
+		for _, entry := range wishlist {
+			fmt.Fprintf(writer, "%s\t%d/%d\t%s\t%s\n", entry.Query, entry.WishlistSearches, envInt("WISHLIST_MAX_SEARCHES", 30),
+				entry.UpdatedAt.Format(time.DateTime), entry.UpdatedAt.Add(interval).Format(time.DateTime))
+		}
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	return 0
+}